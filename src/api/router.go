@@ -26,6 +26,13 @@ func Router(w *engine.Response, r *engine.Request) {
 		controller := parts[2]
 
 		if askForSingleResource(len(parts)) {
+			query := strings.Split(parts[len(parts)-1], "?")
+			parts[len(parts)-1] = query[0]
+
+			if len(query) > 1 {
+				r.Query = getQueryParameters(query[1])
+			}
+
 			r.Params = parts[3:]
 			r.NeedSingleResource = true
 		} else {
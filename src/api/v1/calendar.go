@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Calendar is a list of animes grouped by airing weekday
+type Calendar struct {
+	Days   []CalendarDay      `json:"days"`
+	From   time.Time          `json:"from"`
+	Season models.AnimeSeason `json:"season,omitempty"`
+	To     time.Time          `json:"to"`
+	Year   int                `json:"year,omitempty"`
+}
+
+// CalendarDay is the list of animes airing on a weekday
+type CalendarDay struct {
+	Animes  []CalendarEntry `json:"animes"`
+	Date    string          `json:"date,omitempty"`
+	Weekday string          `json:"weekday"`
+}
+
+// CalendarEntry is an anime of a calendar day, with the newest episode
+// number known from the episodes collection
+type CalendarEntry struct {
+	AiringAt      *time.Time   `json:"airing_at,omitempty"`
+	Anime         models.Anime `json:"anime"`
+	Episode       int          `json:"episode,omitempty"`
+	LatestEpisode int          `json:"latest_episode"`
+}
+
+// CalendarHandler handle all calendar controller requests
+// The calendar lists the episodes expected to air during the week of the
+// date parameter, today by default, grouped by weekday in the timezone
+// parameter, UTC by default
+func CalendarHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		getCalendar(w, r)
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getCalendar(w *engine.Response, r *engine.Request) {
+	loc, ok := getCalendarLocation(w, r)
+
+	if !ok {
+		return
+	}
+
+	day := time.Now().In(loc)
+
+	if value, _ := url.QueryUnescape(r.Query["date"]); value != "" {
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", value, loc)
+
+		if err != nil {
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting date, expected YYYY-MM-DD format")
+			return
+		}
+	}
+
+	from := time.Date(day.Year(), day.Month(), day.Day()-calendarDayIndex(day.Weekday()), 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 7)
+
+	animes, err := models.FindAnimesByStatus(models.AnimeStatusAiring, models.AnimeStatusNotYet)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	calendar := newCalendar(from, to)
+
+	for i := range calendar.Days {
+		calendar.Days[i].Date = from.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	for _, a := range animes {
+		next, ok := a.NextEpisode(from.Add(-time.Nanosecond))
+
+		if !ok || !next.AiringAt.Before(to) {
+			continue
+		}
+
+		airingAt := next.AiringAt.In(loc)
+		i := calendarDayIndex(airingAt.Weekday())
+
+		calendar.Days[i].Animes = append(calendar.Days[i].Animes, CalendarEntry{
+			AiringAt: &airingAt,
+			Anime:    a,
+			Episode:  next.Episode,
+		})
+	}
+
+	for _, d := range calendar.Days {
+		sort.SliceStable(d.Animes, func(i, j int) bool {
+			return d.Animes[i].AiringAt.Before(*d.Animes[j].AiringAt)
+		})
+	}
+
+	writeCalendar(w, calendar)
+}
+
+// newCalendar returns an empty calendar, with a day for each weekday
+// starting from monday
+func newCalendar(from time.Time, to time.Time) *Calendar {
+	calendar := &Calendar{
+		Days: make([]CalendarDay, 7),
+		From: from,
+		To:   to,
+	}
+
+	for i := range calendar.Days {
+		calendar.Days[i] = CalendarDay{
+			Animes:  make([]CalendarEntry, 0),
+			Weekday: strings.ToLower(time.Weekday((i + 1) % 7).String()),
+		}
+	}
+
+	return calendar
+}
+
+// calendarDayIndex returns the position of a weekday in a week starting on monday
+func calendarDayIndex(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+// getCalendarLocation parses the timezone parameter, writing the error
+// response when the timezone is not known
+func getCalendarLocation(w *engine.Response, r *engine.Request) (*time.Location, bool) {
+	name, _ := url.QueryUnescape(r.Query["timezone"])
+
+	if name == "" {
+		return time.UTC, true
+	}
+
+	loc, err := time.LoadLocation(name)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Unknown timezone "+name)
+		return nil, false
+	}
+
+	return loc, true
+}
+
+// writeCalendar fills the latest episode numbers of a calendar and writes it
+func writeCalendar(w *engine.Response, calendar *Calendar) {
+	var ids []int
+
+	for _, d := range calendar.Days {
+		for _, e := range d.Animes {
+			ids = append(ids, e.Anime.ID)
+		}
+	}
+
+	if len(ids) > 0 {
+		numbers, err := models.FindLatestEpisodeNumbers(ids)
+
+		if err != nil {
+			w.WriteJSONError(http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, d := range calendar.Days {
+			for i := range d.Animes {
+				d.Animes[i].LatestEpisode = numbers[d.Animes[i].Anime.ID]
+			}
+		}
+	}
+
+	json, err := json.Marshal(calendar)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, string(json))
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"encoding/json"
+	"net/http"
+)
+
+// CheckpointHandler handle all checkpoint controller requests
+// Resetting the checkpoint requires the admin token
+func CheckpointHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		getCheckpoint(w, r)
+	case "DELETE":
+		resetCheckpoint(w, r)
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getCheckpoint(w *engine.Response, r *engine.Request) {
+	checkpoint, err := models.GetCheckpoint(engine.MALCheckpointName)
+
+	if err == models.ErrNotFound {
+		w.NotFound()
+		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json, err := json.Marshal(checkpoint)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, string(json))
+}
+
+func resetCheckpoint(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	err := engine.ResetMALCheckpoint()
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while resetting checkpoint")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, "")
+}
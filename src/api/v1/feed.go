@@ -0,0 +1,347 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedSize is the number of entries of a feed
+var FeedSize = 50
+
+// RSS is the root element of an RSS 2.0 feed
+type RSS struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel RSSChannel `xml:"channel"`
+}
+
+// RSSChannel is the channel element of an RSS 2.0 feed
+type RSSChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      AtomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []RSSItem `xml:"item"`
+}
+
+// RSSItem is an item element of an RSS 2.0 feed
+type RSSItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	GUID        RSSGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+}
+
+// RSSGUID is the guid element of an RSS 2.0 item
+type RSSGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// AtomFeed is the root element of an Atom feed
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []AtomLink  `xml:"link"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+// AtomEntry is an entry element of an Atom feed
+type AtomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Link       AtomLink       `xml:"link"`
+	Summary    AtomText       `xml:"summary"`
+	Categories []AtomCategory `xml:"category"`
+}
+
+// AtomLink is a link element of an Atom feed
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// AtomText is a text construct of an Atom feed
+type AtomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// AtomCategory is a category element of an Atom feed
+type AtomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// feedRequest is a parsed feed request
+type feedRequest struct {
+	base   string
+	filter models.NotificationFilter
+	format string
+	link   string
+	title  string
+}
+
+// FeedHandler handle all feed controller requests
+// Feeds are requested as /feed/{rss|atom}, /feed/{rss|atom}/episodes and
+// /feed/{rss|atom}/anime/{id}, filtered by the region and from parameters
+func FeedHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		if !r.NeedSingleResource {
+			w.NotFound()
+			return
+		}
+
+		getFeed(w, r)
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getFeed(w *engine.Response, r *engine.Request) {
+	feed, ok := getFeedRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	feed.filter.Limit = FeedSize
+
+	notifications, err := models.FindNotifications(feed.filter)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var out []byte
+	var contentType string
+
+	switch feed.format {
+	case "rss":
+		out, err = xml.MarshalIndent(buildRSS(feed, notifications), "", "  ")
+		contentType = "application/rss+xml; charset=utf-8"
+	case "atom":
+		out, err = xml.MarshalIndent(buildAtom(feed, notifications), "", "  ")
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into XML format")
+		return
+	}
+
+	w.Writer.Header().Set("Content-Type", contentType)
+	w.Write(http.StatusOK, xml.Header+string(out))
+}
+
+// getFeedRequest parses the feed path and filters, writing the error
+// response when they are not valid
+func getFeedRequest(w *engine.Response, r *engine.Request) (*feedRequest, bool) {
+	base := getBaseURL(r)
+
+	feed := &feedRequest{
+		base:   base,
+		format: r.Params[0],
+		link:   base + r.Data.URL.RequestURI(),
+		title:  "AniAPI notifications",
+	}
+
+	if feed.format != "rss" && feed.format != "atom" {
+		w.NotFound()
+		return nil, false
+	}
+
+	path := r.Params[1:]
+
+	switch {
+	case len(path) == 0:
+	case len(path) == 1 && path[0] == "episodes":
+		feed.filter.Type = models.TypeEpisodeChange
+		feed.title = "AniAPI new episodes"
+	case len(path) == 2 && path[0] == "anime":
+		id, err := strconv.Atoi(path[1])
+
+		if err != nil {
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting anime id into Int32 type")
+			return nil, false
+		}
+
+		anime, err := models.GetAnime(id)
+
+		if err != nil {
+			w.NotFound()
+			return nil, false
+		}
+
+		feed.filter.AnimeIDs = []int{id}
+		feed.title = "AniAPI " + anime.MainTitle + " notifications"
+	default:
+		w.NotFound()
+		return nil, false
+	}
+
+	region, _ := url.QueryUnescape(r.Query["region"])
+
+	if region != "" {
+		if region != string(models.RegionIT) && region != string(models.RegionEN) {
+			w.WriteJSONError(http.StatusBadRequest, "Unknown region")
+			return nil, false
+		}
+
+		feed.filter.Region = models.EpisodeRegion(region)
+		feed.title += " (" + region + ")"
+	}
+
+	feed.filter.From, _ = url.QueryUnescape(r.Query["from"])
+
+	if feed.filter.From != "" {
+		feed.title += " from " + feed.filter.From
+	}
+
+	return feed, true
+}
+
+func buildRSS(feed *feedRequest, notifications []models.Notification) *RSS {
+	rss := &RSS{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: RSSChannel{
+			Title:       feed.title,
+			Link:        feed.link,
+			Description: feed.title,
+			AtomLink: AtomLink{
+				Href: feed.link,
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+		},
+	}
+
+	if len(notifications) > 0 {
+		rss.Channel.LastBuildDate = notifications[0].CreationDate.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, n := range notifications {
+		rss.Channel.Items = append(rss.Channel.Items, RSSItem{
+			Title:       getFeedEntryTitle(n),
+			Link:        getFeedEntryLink(feed, n),
+			Description: n.Message,
+			GUID: RSSGUID{
+				IsPermaLink: "false",
+				Value:       getFeedEntryID(n),
+			},
+			PubDate:    n.CreationDate.UTC().Format(time.RFC1123Z),
+			Categories: getFeedEntryCategories(n),
+		})
+	}
+
+	return rss
+}
+
+func buildAtom(feed *feedRequest, notifications []models.Notification) *AtomFeed {
+	atom := &AtomFeed{
+		Title:   feed.title,
+		ID:      feed.link,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []AtomLink{
+			{Href: feed.link, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+
+	if len(notifications) > 0 {
+		atom.Updated = notifications[0].CreationDate.UTC().Format(time.RFC3339)
+	}
+
+	for _, n := range notifications {
+		entry := AtomEntry{
+			Title:   getFeedEntryTitle(n),
+			ID:      getFeedEntryID(n),
+			Updated: n.CreationDate.UTC().Format(time.RFC3339),
+			Link: AtomLink{
+				Href: getFeedEntryLink(feed, n),
+				Rel:  "alternate",
+			},
+			Summary: AtomText{
+				Type: "html",
+				Body: n.Message,
+			},
+		}
+
+		for _, c := range getFeedEntryCategories(n) {
+			entry.Categories = append(entry.Categories, AtomCategory{Term: c})
+		}
+
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return atom
+}
+
+func getFeedEntryTitle(n models.Notification) string {
+	if n.Anime != nil && n.Anime.MainTitle != "" {
+		return n.Anime.MainTitle + ": " + n.Summary()
+	}
+
+	return n.Summary()
+}
+
+// getFeedEntryLink returns the MAL page of the notification anime, or its
+// API resource when it has none
+func getFeedEntryLink(feed *feedRequest, n models.Notification) string {
+	if n.Anime != nil && n.Anime.MyAnimeListID != 0 {
+		return engine.GetMALURL(n.Anime.MyAnimeListID)
+	}
+
+	return fmt.Sprintf("%s/api/v1/anime/%d", feed.base, n.AnimeID)
+}
+
+func getFeedEntryID(n models.Notification) string {
+	return "urn:aniapi:notification:" + n.MongoID.Hex()
+}
+
+func getFeedEntryCategories(n models.Notification) []string {
+	categories := []string{string(n.Type)}
+
+	if n.From != "" {
+		categories = append(categories, n.From)
+	}
+
+	if n.Region != "" {
+		categories = append(categories, string(n.Region))
+	}
+
+	return categories
+}
+
+// getBaseURL returns the public base URL of the API, read from the
+// PUBLIC_URL env var or guessed from the request
+func getBaseURL(r *engine.Request) string {
+	if base := os.Getenv("PUBLIC_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+
+	scheme := "http"
+
+	if r.Data.TLS != nil || r.Data.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Data.Host
+}
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICalDefaultDuration is the duration of the events of animes without one, in minutes
+var ICalDefaultDuration = 24
+
+const icalDateFormat = "20060102T150405Z"
+
+// ICalHandler handle all iCalendar controller requests
+// Events are generated for every airing and upcoming anime, or for the
+// animes of the anime_id comma separated list
+func ICalHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		getICal(w, r)
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getICal(w *engine.Response, r *engine.Request) {
+	ids, err := parseIDList(r.Query["anime_id"])
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while converting anime id into Int32 type")
+		return
+	}
+
+	var animes []models.Anime
+
+	if len(ids) > 0 {
+		animes, err = models.FindAnimesByIDs(ids)
+	} else {
+		animes, err = models.FindAnimesByStatus(models.AnimeStatusAiring, models.AnimeStatusNotYet)
+	}
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Writer.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Writer.Header().Set("Content-Disposition", "inline; filename=\"aniapi.ics\"")
+	w.Write(http.StatusOK, buildICal(animes, time.Now()))
+}
+
+// buildICal renders the next expected episode of every anime as an iCalendar
+func buildICal(animes []models.Anime, now time.Time) string {
+	var b strings.Builder
+
+	writeICalLine(&b, "BEGIN:VCALENDAR")
+	writeICalLine(&b, "VERSION:2.0")
+	writeICalLine(&b, "PRODID:-//AniAPI//Airing schedule//EN")
+	writeICalLine(&b, "CALSCALE:GREGORIAN")
+	writeICalLine(&b, "METHOD:PUBLISH")
+	writeICalLine(&b, "X-WR-CALNAME:AniAPI airing schedule")
+	writeICalLine(&b, "REFRESH-INTERVAL;VALUE=DURATION:PT12H")
+	writeICalLine(&b, "X-PUBLISHED-TTL:PT12H")
+
+	for _, a := range animes {
+		next, ok := a.NextEpisode(now)
+
+		if !ok {
+			continue
+		}
+
+		duration := a.Duration
+
+		if duration == 0 {
+			duration = ICalDefaultDuration
+		}
+
+		link := ""
+
+		if a.MyAnimeListID != 0 {
+			link = engine.GetMALURL(a.MyAnimeListID)
+		}
+
+		description := fmt.Sprintf("Episode %d of %s", next.Episode, a.MainTitle)
+
+		if a.Episodes > 0 {
+			description = fmt.Sprintf("Episode %d of %d of %s", next.Episode, a.Episodes, a.MainTitle)
+		}
+
+		if a.Broadcast != "" {
+			description += "\nBroadcast: " + a.Broadcast
+		}
+
+		if a.Picture != "" {
+			description += "\nPicture: " + a.Picture
+		}
+
+		if link != "" {
+			description += "\n" + link
+		}
+
+		writeICalLine(&b, "BEGIN:VEVENT")
+		writeICalLine(&b, fmt.Sprintf("UID:anime-%d-episode-%d@aniapi", a.ID, next.Episode))
+		writeICalLine(&b, "DTSTAMP:"+now.UTC().Format(icalDateFormat))
+		writeICalLine(&b, "DTSTART:"+next.AiringAt.UTC().Format(icalDateFormat))
+		writeICalLine(&b, "DTEND:"+next.AiringAt.Add(time.Duration(duration)*time.Minute).UTC().Format(icalDateFormat))
+		writeICalLine(&b, "SUMMARY:"+escapeICalText(a.MainTitle+" - Episode "+strconv.Itoa(next.Episode)))
+		writeICalLine(&b, "DESCRIPTION:"+escapeICalText(description))
+		categories := []string{"Anime"}
+
+		for _, g := range a.Genres {
+			categories = append(categories, escapeICalText(g))
+		}
+
+		writeICalLine(&b, "CATEGORIES:"+strings.Join(categories, ","))
+
+		if link != "" {
+			writeICalLine(&b, "URL:"+link)
+		}
+
+		if a.Picture != "" {
+			writeICalLine(&b, "IMAGE;VALUE=URI;DISPLAY=THUMBNAIL:"+a.Picture)
+			writeICalLine(&b, "ATTACH;FMTTYPE=image/jpeg:"+a.Picture)
+		}
+
+		writeICalLine(&b, "END:VEVENT")
+	}
+
+	writeICalLine(&b, "END:VCALENDAR")
+
+	return b.String()
+}
+
+// escapeICalText escapes a TEXT property value
+func escapeICalText(s string) string {
+	r := strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", "\n", "\\n")
+
+	return r.Replace(s)
+}
+
+// writeICalLine writes a content line, folded at 75 octets as required by RFC 5545
+// Continuation lines start with a space, which counts in their length
+func writeICalLine(b *strings.Builder, line string) {
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+
+		for cut > 0 && !isUTF8Start(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func isUTF8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
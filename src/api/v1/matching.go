@@ -103,7 +103,12 @@ func increaseMatchingVotes(w *engine.Response, r *engine.Request) {
 		return
 	}
 
-	engine.InsertItemInQueue(engine.NewQueueItem(matching.AnimeID))
+	_, err = engine.InsertItemInQueue(matching.AnimeID, models.QueuePriorityHigh)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while queueing anime")
+		return
+	}
 
 	w.WriteJSON(http.StatusOK, "")
 }
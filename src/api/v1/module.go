@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"aniapi-go/modules"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ModuleHandler handle all module controller requests
+// Changing module settings requires the admin token
+func ModuleHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		if r.NeedSingleResource {
+			getOneModule(w, r)
+		} else {
+			getMoreModules(w, r)
+		}
+	case "POST":
+		if r.NeedSingleResource {
+			updateModule(w, r)
+		} else {
+			w.NotImplemented()
+		}
+	case "DELETE":
+		if r.NeedSingleResource {
+			resetModule(w, r)
+		} else {
+			w.NotImplemented()
+		}
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getOneModule(w *engine.Response, r *engine.Request) {
+	status, err := modules.GetStatus(r.Params[0])
+
+	if err != nil {
+		w.NotFound()
+		return
+	}
+
+	writeModuleStatus(w, status)
+}
+
+func getMoreModules(w *engine.Response, r *engine.Request) {
+	json, err := json.Marshal(modules.GetStatuses())
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, string(json))
+}
+
+func updateModule(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	body := &models.ModuleSettingsPatch{}
+
+	err := json.NewDecoder(r.Data.Body).Decode(body)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while formatting request body into JSON format")
+		return
+	}
+
+	status, err := modules.Configure(r.Params[0], *body)
+
+	if err == modules.ErrUnknownModule {
+		w.NotFound()
+		return
+	} else if err == modules.ErrInvalidSettings {
+		w.WriteJSONError(http.StatusBadRequest, fmt.Sprintf("Module settings need an http or https base_url, a known region and a concurrency between 1 and %d", models.ModuleMaxConcurrency))
+		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while updating model")
+		return
+	}
+
+	writeModuleStatus(w, status)
+}
+
+func resetModule(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	status, err := modules.Reset(r.Params[0])
+
+	if err == modules.ErrUnknownModule {
+		w.NotFound()
+		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while updating model")
+		return
+	}
+
+	writeModuleStatus(w, status)
+}
+
+func writeModuleStatus(w *engine.Response, status modules.ModuleStatus) {
+	json, err := json.Marshal(status)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, string(json))
+}
@@ -4,63 +4,105 @@ import (
 	"aniapi-go/engine"
 	"aniapi-go/models"
 	"encoding/json"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// NotificationKeepAliveInterval is the interval between notification stream keep-alive comments
+var NotificationKeepAliveInterval = 30 * time.Second
+
 // NotificationHandler handle all notification controller requests
 func NotificationHandler(w *engine.Response, r *engine.Request) {
 	switch r.Data.Method {
 	case "GET":
-		getMoreNotification(w, r)
+		if !r.NeedSingleResource {
+			getMoreNotification(w, r)
+		} else if r.Params[0] == "stream" {
+			streamNotifications(w, r)
+		} else {
+			w.NotFound()
+		}
 	default:
 		w.NotImplemented()
 	}
 }
 
+// NotificationPageSize is the default number of notifications per page
+var NotificationPageSize = 100
+
+// NotificationMaxPageSize is the maximum number of notifications per page
+var NotificationMaxPageSize = 500
+
+// getMoreNotification returns a page of notifications, from the most recent
+// The next page is requested with the cursor query parameter set to the id
+// of the last notification, which is also sent in the X-Next-Cursor header
+// when more notifications may follow
 func getMoreNotification(w *engine.Response, r *engine.Request) {
-	var animeIDs []int
-	var anilistIDs []int
+	filter, ok := getNotificationFilter(w, r)
+
+	if !ok {
+		return
+	}
 
-	animeIDList := strings.Split(r.Query["anime_id"], ",")
-	anilistIDList := strings.Split(r.Query["anilist_id"], ",")
+	if days := r.Query["days"]; days != "" {
+		d, err := strconv.Atoi(days)
 
-	for _, animeID := range animeIDList {
-		if animeID == "" {
-			continue
+		if err != nil || d < 1 {
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting days into a positive Int32 type")
+			return
 		}
 
-		id, err := strconv.Atoi(animeID)
+		window := time.Duration(d) * 24 * time.Hour
+
+		if window > models.NotificationMaxWindow {
+			window = models.NotificationMaxWindow
+		}
+
+		filter.Since = time.Now().Add(-window)
+	}
+
+	if cursor := r.Query["cursor"]; cursor != "" {
+		before, err := primitive.ObjectIDFromHex(cursor)
 
 		if err != nil {
-			w.WriteJSONError(http.StatusBadRequest, "Error while converting anime id into Int32 type")
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting cursor into an id")
 			return
 		}
 
-		animeIDs = append(animeIDs, id)
+		filter.Before = before
 	}
 
-	for _, anilistID := range anilistIDList {
-		if anilistID == "" {
-			continue
-		}
+	filter.Limit = NotificationPageSize
 
-		id, err := strconv.Atoi(anilistID)
+	if limit := r.Query["limit"]; limit != "" {
+		l, err := strconv.Atoi(limit)
 
-		if err != nil {
-			w.WriteJSONError(http.StatusBadRequest, "Error while converting anilist id into Int32 type")
+		if err != nil || l < 1 {
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting limit into a positive Int32 type")
 			return
 		}
 
-		anilistIDs = append(anilistIDs, id)
+		if l > NotificationMaxPageSize {
+			l = NotificationMaxPageSize
+		}
+
+		filter.Limit = l
 	}
 
-	notifications, err := models.FindNotifications(animeIDs, anilistIDs)
+	notifications, err := models.FindNotifications(filter)
 
-	if err != nil {
+	if err == models.ErrNotFound {
 		w.NotFound()
 		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	json, err := json.Marshal(notifications)
@@ -70,5 +112,145 @@ func getMoreNotification(w *engine.Response, r *engine.Request) {
 		return
 	}
 
+	if len(notifications) == filter.Limit {
+		w.Writer.Header().Set("X-Next-Cursor", notifications[len(notifications)-1].MongoID.Hex())
+	}
+
 	w.WriteJSON(http.StatusOK, string(json))
 }
+
+// streamNotifications streams the notification events as Server-Sent Events
+// Event ids are the notifications ids, so a client reconnecting with a
+// Last-Event-ID header first receives the notifications created after it
+func streamNotifications(w *engine.Response, r *engine.Request) {
+	filter, ok := getNotificationFilter(w, r)
+
+	if !ok {
+		return
+	}
+
+	lastEventID := r.Data.Header.Get("Last-Event-ID")
+
+	if lastEventID == "" {
+		lastEventID, _ = url.QueryUnescape(r.Query["last_event_id"])
+	}
+
+	if lastEventID != "" {
+		after, err := primitive.ObjectIDFromHex(lastEventID)
+
+		if err != nil {
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting last event id into an id")
+			return
+		}
+
+		filter.After = after
+	}
+
+	stream := engine.NewNotificationStream(filter.AnimeIDs, filter.AnilistIDs)
+	defer stream.Close()
+
+	if !w.StartEventStream() {
+		w.WriteJSONError(http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	sent := make(map[primitive.ObjectID]bool)
+
+	if !filter.After.IsZero() {
+		missed, err := models.FindNotifications(filter)
+
+		if err != nil {
+			log.Printf("NOTIFICATION STREAM RESUME ERROR: %s", err.Error())
+		}
+
+		for i := len(missed) - 1; i >= 0; i-- {
+			if !writeNotificationEvent(w, missed[i]) {
+				return
+			}
+
+			sent[missed[i].MongoID] = true
+		}
+	}
+
+	keepAlive := time.NewTicker(NotificationKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Data.Context().Done():
+			return
+		case n := <-stream.Events:
+			if sent[n.MongoID] {
+				continue
+			}
+
+			if !writeNotificationEvent(w, n) {
+				return
+			}
+		case <-keepAlive.C:
+			if w.WriteEvent("", "", "") != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeNotificationEvent(w *engine.Response, n models.Notification) bool {
+	data, err := json.Marshal(n)
+
+	if err != nil {
+		log.Printf("NOTIFICATION STREAM JSON ERROR: %s", err.Error())
+		return true
+	}
+
+	return w.WriteEvent(n.MongoID.Hex(), engine.NotificationChannel, string(data)) == nil
+}
+
+// getNotificationFilter parses the anime_id and anilist_id comma separated
+// lists, writing the error response when an id is not valid
+func getNotificationFilter(w *engine.Response, r *engine.Request) (models.NotificationFilter, bool) {
+	filter := models.NotificationFilter{}
+
+	var err error
+	filter.AnimeIDs, err = parseIDList(r.Query["anime_id"])
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while converting anime id into Int32 type")
+		return filter, false
+	}
+
+	filter.AnilistIDs, err = parseIDList(r.Query["anilist_id"])
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while converting anilist id into Int32 type")
+		return filter, false
+	}
+
+	return filter, true
+}
+
+func parseIDList(value string) ([]int, error) {
+	var ids []int
+
+	value, err := url.QueryUnescape(value)
+
+	if err != nil {
+		return ids, err
+	}
+
+	for _, v := range strings.Split(value, ",") {
+		if v == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(v)
+
+		if err != nil {
+			return ids, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
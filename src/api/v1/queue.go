@@ -0,0 +1,239 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// QueueRequest is the request body of the queue insertion and update
+type QueueRequest struct {
+	AnimeID  int `json:"anime_id"`
+	Priority int `json:"priority"`
+}
+
+// QueueHandler handle all queue controller requests
+// Adding, updating and cancelling queue items requires the admin token
+func QueueHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		if r.NeedSingleResource {
+			getOneQueueItem(w, r)
+		} else {
+			getMoreQueueItems(w, r)
+		}
+	case "PUT":
+		addQueueItem(w, r)
+	case "POST":
+		if r.NeedSingleResource {
+			updateQueueItem(w, r)
+		} else {
+			w.NotImplemented()
+		}
+	case "DELETE":
+		if r.NeedSingleResource {
+			cancelQueueItem(w, r)
+		} else {
+			w.NotImplemented()
+		}
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getOneQueueItem(w *engine.Response, r *engine.Request) {
+	item, ok := getQueueItemParam(w, r)
+
+	if !ok {
+		return
+	}
+
+	item.Anime, _ = models.GetAnime(item.AnimeID)
+
+	writeQueueItem(w, item)
+}
+
+func getMoreQueueItems(w *engine.Response, r *engine.Request) {
+	pageNumber, _ := strconv.Atoi(r.Query["page"])
+	page := utils.GetPageInfo(pageNumber)
+
+	status, _ := url.QueryUnescape(r.Query["status"])
+
+	if status != "" && !models.IsValidQueueStatus(models.QueueStatus(status)) {
+		w.WriteJSONError(http.StatusBadRequest, "Unknown queue status")
+		return
+	}
+
+	items, err := models.FindQueueItems(models.QueueStatus(status), page)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	json, err := json.Marshal(items)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, string(json))
+}
+
+func addQueueItem(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	body := &QueueRequest{
+		Priority: models.QueuePriorityNormal,
+	}
+
+	err := json.NewDecoder(r.Data.Body).Decode(body)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while formatting request body into JSON format")
+		return
+	}
+
+	_, err = models.GetAnime(body.AnimeID)
+
+	if err != nil {
+		w.NotFound()
+		return
+	}
+
+	item, err := engine.InsertItemInQueue(body.AnimeID, body.Priority)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while queueing anime")
+		return
+	}
+
+	writeQueueItem(w, item)
+}
+
+func updateQueueItem(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	item, ok := getQueueItemParam(w, r)
+
+	if !ok {
+		return
+	}
+
+	body := &QueueRequest{}
+
+	err := json.NewDecoder(r.Data.Body).Decode(body)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while formatting request body into JSON format")
+		return
+	}
+
+	err = item.SetPriority(body.Priority)
+
+	if err == models.ErrNotFound {
+		w.WriteJSONError(http.StatusConflict, "Only pending queue items can be updated")
+		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while updating model")
+		return
+	}
+
+	writeQueueItem(w, item)
+}
+
+func cancelQueueItem(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	item, ok := getQueueItemParam(w, r)
+
+	if !ok {
+		return
+	}
+
+	err := item.Cancel()
+
+	if err == models.ErrNotFound {
+		done, running := engine.CancelRunningQueueItem(item.ID)
+
+		if !running {
+			w.WriteJSONError(http.StatusConflict, "Only pending or running queue items can be cancelled")
+			return
+		}
+
+		status := http.StatusOK
+
+		select {
+		case <-done:
+		case <-time.After(engine.QueueCancelWait):
+			status = http.StatusAccepted
+		}
+
+		item, ok = getQueueItemParam(w, r)
+
+		if !ok {
+			return
+		}
+
+		writeQueueItemStatus(w, status, item)
+		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while updating model")
+		return
+	}
+
+	writeQueueItem(w, item)
+}
+
+// getQueueItemParam returns the queue item referenced by the request path,
+// writing the error response when it can not be found
+func getQueueItemParam(w *engine.Response, r *engine.Request) (*models.QueueItem, bool) {
+	id, err := strconv.Atoi(r.Params[0])
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while converting queue item id into Int32 type")
+		return nil, false
+	}
+
+	item, err := models.GetQueueItem(id)
+
+	if err == models.ErrNotFound {
+		w.NotFound()
+		return nil, false
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	return item, true
+}
+
+func writeQueueItem(w *engine.Response, item *models.QueueItem) {
+	writeQueueItemStatus(w, http.StatusOK, item)
+}
+
+func writeQueueItemStatus(w *engine.Response, status int, item *models.QueueItem) {
+	json, err := json.Marshal(item)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(status, string(json))
+}
@@ -17,6 +17,22 @@ func Router(controller string, w *engine.Response, r *engine.Request) {
 		SocketHandler(w, r)
 	case "proxy":
 		ProxyHandler(w, r)
+	case "checkpoint":
+		CheckpointHandler(w, r)
+	case "queue":
+		QueueHandler(w, r)
+	case "calendar":
+		CalendarHandler(w, r)
+	case "season":
+		SeasonHandler(w, r)
+	case "calendar.ics":
+		ICalHandler(w, r)
+	case "feed":
+		FeedHandler(w, r)
+	case "webhook":
+		WebhookHandler(w, r)
+	case "module":
+		ModuleHandler(w, r)
 	default:
 		w.NotFound()
 	}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SeasonHandler handle all season controller requests
+// The season lists the animes of /season/{year}/{season}, the current one
+// by default, grouped by airing weekday in the timezone parameter
+func SeasonHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		getSeason(w, r)
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getSeason(w *engine.Response, r *engine.Request) {
+	loc, ok := getCalendarLocation(w, r)
+
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	year, season := now.Year(), models.GetSeason(now)
+
+	if r.NeedSingleResource {
+		if len(r.Params) != 2 {
+			w.WriteJSONError(http.StatusBadRequest, "Season must be requested as /season/{year}/{season}")
+			return
+		}
+
+		var err error
+		year, err = strconv.Atoi(r.Params[0])
+
+		if err != nil || year < 1900 || year > 3000 {
+			w.WriteJSONError(http.StatusBadRequest, "Error while converting season year into Int32 type")
+			return
+		}
+
+		season, ok = models.ParseAnimeSeason(r.Params[1])
+
+		if !ok {
+			w.WriteJSONError(http.StatusBadRequest, "Season must be one of winter, spring, summer or fall")
+			return
+		}
+	}
+
+	animes, err := models.FindAnimesBySeason(season, year)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	from, to := season.Dates(year)
+
+	calendar := newCalendar(from, to)
+	calendar.Season = season
+	calendar.Year = year
+
+	calendar.Days = append(calendar.Days, CalendarDay{
+		Animes:  make([]CalendarEntry, 0),
+		Weekday: "unknown",
+	})
+
+	for _, a := range animes {
+		i := len(calendar.Days) - 1
+
+		if weekday, ok := getAiringWeekday(&a, from, loc); ok {
+			i = calendarDayIndex(weekday)
+		}
+
+		calendar.Days[i].Animes = append(calendar.Days[i].Animes, CalendarEntry{
+			Anime: a,
+		})
+	}
+
+	writeCalendar(w, calendar)
+}
+
+// getAiringWeekday returns the weekday an anime airs on
+// Sources are, in order of precedence, the MAL broadcast, the AniList next
+// airing episode and the airing start date, every one read in the timezone
+// of the request
+func getAiringWeekday(a *models.Anime, from time.Time, loc *time.Location) (time.Weekday, bool) {
+	if b, ok := models.ParseBroadcast(a.Broadcast); ok {
+		return b.Next(from).In(loc).Weekday(), true
+	}
+
+	if a.NextAiringEpisode != nil && !a.NextAiringEpisode.AiringAt.IsZero() {
+		return a.NextAiringEpisode.AiringAt.In(loc).Weekday(), true
+	}
+
+	if a.AiringStartPrecision == utils.DatePrecisionDay {
+		return a.AiringStart.In(loc).Weekday(), true
+	}
+
+	return time.Sunday, false
+}
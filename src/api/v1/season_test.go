@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSeasonWeekdayTimezone checks that an anime known by its airing start
+// only is listed on the weekday of the requested timezone
+func TestSeasonWeekdayTimezone(t *testing.T) {
+	models.SetStore(models.NewMemoryStore())
+
+	anime := &models.Anime{
+		// Sunday at midnight in Japan, still Saturday in UTC
+		AiringStart:          time.Date(2021, time.January, 9, 15, 0, 0, 0, time.UTC),
+		AiringStartPrecision: utils.DatePrecisionDay,
+		MainTitle:            "Season Test",
+		MyAnimeListID:        1,
+		Season:               models.SeasonWinter,
+		SeasonYear:           2021,
+		Type:                 "TV",
+	}
+
+	if err := anime.Save(); err != nil {
+		t.Fatalf("anime not saved: %s", err.Error())
+	}
+
+	cases := map[string]string{
+		"":                 "saturday",
+		"Asia/Tokyo":       "sunday",
+		"America/New_York": "saturday",
+	}
+
+	for timezone, expected := range cases {
+		rec := httptest.NewRecorder()
+
+		SeasonHandler(&engine.Response{Writer: rec}, &engine.Request{
+			Data:               httptest.NewRequest("GET", "/api/v1/season/2021/winter", nil),
+			NeedSingleResource: true,
+			Params:             []string{"2021", "winter"},
+			Query:              map[string]string{"timezone": timezone},
+		})
+
+		calendar := &Calendar{}
+
+		if err := json.Unmarshal(rec.Body.Bytes(), calendar); err != nil {
+			t.Fatalf("%q: calendar not parsed: %s", timezone, err.Error())
+		}
+
+		weekday := ""
+
+		for _, d := range calendar.Days {
+			if len(d.Animes) > 0 {
+				weekday = d.Weekday
+			}
+		}
+
+		if weekday != expected {
+			t.Errorf("%q: expected %s, got %q", timezone, expected, weekday)
+		}
+	}
+}
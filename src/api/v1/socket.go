@@ -6,12 +6,16 @@ import (
 
 // SocketHandler handle a socket connection initialization
 func SocketHandler(w *engine.Response, r *engine.Request) {
-	engine.OnSocketConnStart(w, r)
+	client := engine.OnSocketConnStart(w, r)
+
+	if client == nil {
+		return
+	}
 
 	msg := &engine.SocketMessage{
 		Channel: "queue",
-		Data:    engine.QueueItems,
+		Data:    engine.GetActiveQueueItems(),
 	}
 
-	go engine.SocketWriteMessage(msg)
+	client.WriteMessage(msg)
 }
@@ -0,0 +1,199 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// WebhookHandler handle all webhook controller requests
+// Every webhook request requires the admin token, as webhook urls and
+// deliveries may carry the receivers credentials
+func WebhookHandler(w *engine.Response, r *engine.Request) {
+	switch r.Data.Method {
+	case "GET":
+		if !r.NeedSingleResource {
+			getMoreWebhook(w, r)
+		} else if len(r.Params) > 1 && r.Params[1] == "delivery" {
+			getWebhookDeliveries(w, r)
+		} else {
+			getOneWebhook(w, r)
+		}
+	case "PUT":
+		addWebhook(w, r)
+	case "DELETE":
+		if r.NeedSingleResource {
+			deleteWebhook(w, r)
+		} else {
+			w.NotImplemented()
+		}
+	default:
+		w.NotImplemented()
+	}
+}
+
+func getOneWebhook(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	webhook, ok := getWebhookParam(w, r)
+
+	if !ok {
+		return
+	}
+
+	webhook.Secret = ""
+
+	writeWebhookJSON(w, webhook)
+}
+
+func getMoreWebhook(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	webhooks, err := models.FindWebhooks()
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	writeWebhookJSON(w, webhooks)
+}
+
+func addWebhook(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	webhook := &models.Webhook{}
+
+	err := json.NewDecoder(r.Data.Body).Decode(webhook)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while formatting request body into JSON format")
+		return
+	}
+
+	if !webhook.IsValid() {
+		w.WriteJSONError(http.StatusBadRequest, "Webhook needs an http url and known notification types")
+		return
+	}
+
+	err = webhook.CheckHost()
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Webhook url must reach a public host")
+		return
+	}
+
+	err = webhook.Save()
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while saving model")
+		return
+	}
+
+	// The secret is shown only once, to sign the deliveries on the receiver
+	writeWebhookJSON(w, webhook)
+}
+
+func deleteWebhook(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	id, err := strconv.Atoi(r.Params[0])
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while converting webhook id into Int32 type")
+		return
+	}
+
+	err = models.DeleteWebhook(id)
+
+	if err == models.ErrNotFound {
+		w.NotFound()
+		return
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while deleting model")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, "")
+}
+
+func getWebhookDeliveries(w *engine.Response, r *engine.Request) {
+	if !r.IsAdmin() {
+		w.NotAuthorized()
+		return
+	}
+
+	webhook, ok := getWebhookParam(w, r)
+
+	if !ok {
+		return
+	}
+
+	pageNumber, _ := strconv.Atoi(r.Query["page"])
+	page := utils.GetPageInfo(pageNumber)
+
+	status, _ := url.QueryUnescape(r.Query["status"])
+
+	deliveries, err := models.FindWebhookDeliveries(webhook.ID, models.DeliveryStatus(status), page)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeWebhookJSON(w, deliveries)
+}
+
+// getWebhookParam returns the webhook referenced by the request path,
+// writing the error response when it can not be found
+func getWebhookParam(w *engine.Response, r *engine.Request) (*models.Webhook, bool) {
+	id, err := strconv.Atoi(r.Params[0])
+
+	if err != nil {
+		w.WriteJSONError(http.StatusBadRequest, "Error while converting webhook id into Int32 type")
+		return nil, false
+	}
+
+	webhook, err := models.GetWebhook(id)
+
+	if err == models.ErrNotFound {
+		w.NotFound()
+		return nil, false
+	} else if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func writeWebhookJSON(w *engine.Response, v interface{}) {
+	json, err := json.Marshal(v)
+
+	if err != nil {
+		w.WriteJSONError(http.StatusInternalServerError, "Error while formatting response into JSON format")
+		return
+	}
+
+	w.WriteJSON(http.StatusOK, string(json))
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestWebhookReadsRequireAdmin(t *testing.T) {
+	models.SetStore(models.NewMemoryStore())
+	os.Setenv("ADMIN_TOKEN", "secret")
+	defer os.Unsetenv("ADMIN_TOKEN")
+
+	webhook := &models.Webhook{URL: "https://example.com/hook?token=abc"}
+
+	if err := webhook.Save(); err != nil {
+		t.Fatalf("webhook not saved: %s", err.Error())
+	}
+
+	cases := []struct {
+		name   string
+		params []string
+		single bool
+	}{
+		{"list", nil, false},
+		{"one", []string{"1"}, true},
+		{"deliveries", []string{"1", "delivery"}, true},
+	}
+
+	for _, c := range cases {
+		for _, auth := range []string{"", "Bearer wrong", "Bearer secret"} {
+			req := httptest.NewRequest("GET", "/api/v1/webhook", nil)
+
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+
+			rec := httptest.NewRecorder()
+
+			WebhookHandler(&engine.Response{Writer: rec}, &engine.Request{
+				Data:               req,
+				NeedSingleResource: c.single,
+				Params:             c.params,
+				Query:              map[string]string{},
+			})
+
+			expected := http.StatusUnauthorized
+
+			if auth == "Bearer secret" {
+				expected = http.StatusOK
+			}
+
+			if rec.Code != expected {
+				t.Errorf("%s with %q: expected %d, got %d", c.name, auth, expected, rec.Code)
+			}
+		}
+	}
+}
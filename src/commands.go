@@ -0,0 +1,56 @@
+package main
+
+import (
+	"aniapi-go/database"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runCommand executes a CLI subcommand instead of starting the server
+func runCommand(name string, args []string) {
+	switch name {
+	case "migrate":
+		migrateCommand(args)
+	default:
+		fmt.Printf("Unknown command %s\n", name)
+		fmt.Println("Usage: aniapi-go [migrate [status]]")
+		os.Exit(2)
+	}
+}
+
+func migrateCommand(args []string) {
+	database.Init()
+
+	if len(args) > 0 && args[0] == "status" {
+		applied, err := database.GetAppliedMigrations()
+
+		if err != nil {
+			log.Fatalf("Could not read applied migrations: %s\n", err.Error())
+		}
+
+		pending, err := database.GetPendingMigrations()
+
+		if err != nil {
+			log.Fatalf("Could not read pending migrations: %s\n", err.Error())
+		}
+
+		for _, m := range applied {
+			fmt.Printf("%4d applied %s  %s\n", m.Version, m.AppliedDate.Format("2006-01-02 15:04:05"), m.Description)
+		}
+
+		for _, m := range pending {
+			fmt.Printf("%4d pending %19s  %s\n", m.Version, "", m.Description)
+		}
+
+		return
+	}
+
+	err := database.Migrate()
+
+	if err != nil {
+		log.Fatalf("Could not migrate MongoDB: %s\n", err.Error())
+	}
+
+	log.Print("MongoDB is up to date")
+}
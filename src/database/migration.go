@@ -0,0 +1,142 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned change to the MongoDB collections
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *mongo.Database) error
+}
+
+// AppliedMigration is the MongoDB model of an applied migration document
+type AppliedMigration struct {
+	Version     int       `bson:"_id" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	AppliedDate time.Time `bson:"applied_date" json:"applied_date"`
+}
+
+// MigrationCollectionName is a string value of migrations MongoDB collection name
+var MigrationCollectionName string = "migrations"
+
+var migrations []Migration
+
+// RegisterMigration adds a migration to the ones run by Migrate
+// Versions must be unique, migrations are run in ascending version order
+func RegisterMigration(m Migration) {
+	for _, registered := range migrations {
+		if registered.Version == m.Version {
+			log.Fatalf("Migration version %d registered twice", m.Version)
+		}
+	}
+
+	migrations = append(migrations, m)
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// GetDatabase returns the MongoDB database pointer
+func GetDatabase() *mongo.Database {
+	return Conn.Database(db)
+}
+
+// GetAppliedMigrations returns the migrations already applied to the database
+func GetAppliedMigrations() ([]AppliedMigration, error) {
+	var applied []AppliedMigration
+
+	ctx, cancel := GetContext(10)
+	defer cancel()
+	cur, err := GetCollection(MigrationCollectionName).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+
+	if err != nil {
+		return applied, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		m := AppliedMigration{}
+		err = cur.Decode(&m)
+
+		if err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// GetPendingMigrations returns the registered migrations not yet applied
+func GetPendingMigrations() ([]Migration, error) {
+	var pending []Migration
+
+	applied, err := GetAppliedMigrations()
+
+	if err != nil {
+		return pending, err
+	}
+
+	done := make(map[int]bool)
+
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	for _, m := range migrations {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrate applies every pending migration in version order
+// It stops at the first failing migration, which will be retried on the next run
+func Migrate() error {
+	pending, err := GetPendingMigrations()
+
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		start := time.Now()
+		err = m.Up(GetDatabase())
+
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %s", m.Version, m.Description, err.Error())
+		}
+
+		applied := &AppliedMigration{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedDate: time.Now(),
+		}
+
+		ctx, cancel := GetContext(10)
+		_, err = GetCollection(MigrationCollectionName).InsertOne(ctx, applied)
+		cancel()
+
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) not recorded: %s", m.Version, m.Description, err.Error())
+		}
+
+		log.Printf("APPLIED MIGRATION %d (%s) IN %s", m.Version, m.Description, time.Since(start))
+	}
+
+	return nil
+}
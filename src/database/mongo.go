@@ -33,7 +33,8 @@ func Init() {
 		os.Exit(1)
 	}
 
-	ctx := GetContext(10)
+	ctx, cancel := GetContext(10)
+	defer cancel()
 	client, _ := mongo.Connect(ctx, options.Client().ApplyURI(url))
 	err := client.Ping(ctx, readpref.Primary())
 
@@ -47,9 +48,9 @@ func Init() {
 }
 
 // GetContext returns a context in which to execute MongoDB operations
-func GetContext(seconds time.Duration) context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), seconds*time.Second)
-	return ctx
+// The cancel function should be called once the operations are done
+func GetContext(seconds time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), seconds*time.Second)
 }
 
 // GetCollection returns a MongoDB collection pointer
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AniListURL is the AniList GraphQL API endpoint
+const AniListURL = "https://graphql.anilist.co"
+
+const aniListMediaQuery = `query($idMal: Int) {
+	Page(page: 1, perPage: 50) {
+		media(idMal: $idMal, type: ANIME) {
+			id
+			title { romaji english native }
+			description(asHtml: false)
+			episodes
+			duration
+			season
+			seasonYear
+			studios(isMain: true) { nodes { name } }
+			tags { name isMediaSpoiler }
+			bannerImage
+			coverImage { extraLarge large }
+			nextAiringEpisode { airingAt episode }
+		}
+	}
+}`
+
+// ALQuery is the data definition of the AL query
+type ALQuery struct {
+	Query     string         `json:"query"`
+	Variables map[string]int `json:"variables"`
+}
+
+// ALResponse is the data definition of the AL query response
+type ALResponse struct {
+	Data   ALResponsePage    `json:"data"`
+	Errors []ALResponseError `json:"errors"`
+}
+
+// ALResponseError is the data definition of an AL query error
+type ALResponseError struct {
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+// ALResponsePage is the nested data definition of the AL query response
+type ALResponsePage struct {
+	Page ALResponseData `json:"Page"`
+}
+
+// ALResponseData is the nested data definition of the AL query response
+type ALResponseData struct {
+	Media []ALResponseMedia `json:"media"`
+}
+
+// ALResponseMedia is the nested data definition of the AL query response
+type ALResponseMedia struct {
+	ID    int `json:"id"`
+	Title struct {
+		Romaji  string `json:"romaji"`
+		English string `json:"english"`
+		Native  string `json:"native"`
+	} `json:"title"`
+	Description string `json:"description"`
+	Episodes    int    `json:"episodes"`
+	Duration    int    `json:"duration"`
+	Season      string `json:"season"`
+	SeasonYear  int    `json:"seasonYear"`
+	Studios     struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"studios"`
+	Tags []struct {
+		Name           string `json:"name"`
+		IsMediaSpoiler bool   `json:"isMediaSpoiler"`
+	} `json:"tags"`
+	BannerImage string `json:"bannerImage"`
+	CoverImage  struct {
+		ExtraLarge string `json:"extraLarge"`
+		Large      string `json:"large"`
+	} `json:"coverImage"`
+	NextAiringEpisode *struct {
+		AiringAt int64 `json:"airingAt"`
+		Episode  int   `json:"episode"`
+	} `json:"nextAiringEpisode"`
+}
+
+// AniListClient queries the AniList GraphQL API through the shared fetcher
+type AniListClient struct {
+	URL string
+}
+
+// FindByMALID returns the AniList media linked to a MAL id
+// When more than one media matches, the most recent one is returned
+// It returns nil when no media matches
+func (c *AniListClient) FindByMALID(malID int) (*ALResponseMedia, error) {
+	al := &ALQuery{
+		Query: aniListMediaQuery,
+		Variables: map[string]int{
+			"idMal": malID,
+		},
+	}
+
+	data, err := json.Marshal(al)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", c.URL, bytes.NewBuffer(data))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := utils.DefaultFetcher.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	result := &ALResponse{}
+	err = json.NewDecoder(resp.Body).Decode(result)
+
+	if err != nil {
+		return nil, &utils.FetchError{Kind: utils.FetchParseFailure, URL: c.URL, Status: resp.StatusCode, Err: err}
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+
+	var best *ALResponseMedia
+
+	for i, media := range result.Data.Page.Media {
+		if best == nil || media.ID > best.ID {
+			best = &result.Data.Page.Media[i]
+		}
+	}
+
+	return best, nil
+}
+
+// MergeAniListMedia fills an anime scraped from MAL with AniList data
+// Precedence rules are:
+// - the AniList id and the fields MAL does not provide (titles, banner and
+// cover images, tags, next airing episode) are always taken from AniList
+// - fields MAL may provide (description, episodes, duration, season,
+// studios) keep the MAL value and are taken from AniList only when missing
+// - MAL-only fields (main title, type, score, status, airing dates, genres,
+// picture) are never touched
+// English and romaji titles are added to the alternative titles too
+func MergeAniListMedia(a *models.Anime, media *ALResponseMedia) {
+	a.AniListID = media.ID
+
+	a.Titles = models.AnimeTitles{
+		English: media.Title.English,
+		Native:  media.Title.Native,
+		Romaji:  media.Title.Romaji,
+	}
+
+	for _, title := range []string{media.Title.English, media.Title.Romaji} {
+		if title != "" && title != a.MainTitle && !containsString(a.AlternativesTitle, title) {
+			a.AlternativesTitle = append(a.AlternativesTitle, title)
+		}
+	}
+
+	a.BannerImage = media.BannerImage
+	a.CoverImage = media.CoverImage.ExtraLarge
+
+	if a.CoverImage == "" {
+		a.CoverImage = media.CoverImage.Large
+	}
+
+	a.Tags = nil
+
+	for _, tag := range media.Tags {
+		if !tag.IsMediaSpoiler {
+			a.Tags = append(a.Tags, tag.Name)
+		}
+	}
+
+	a.NextAiringEpisode = nil
+
+	if media.NextAiringEpisode != nil {
+		a.NextAiringEpisode = &models.AiringEpisode{
+			AiringAt: time.Unix(media.NextAiringEpisode.AiringAt, 0).UTC(),
+			Episode:  media.NextAiringEpisode.Episode,
+		}
+	}
+
+	if a.Description == "" {
+		a.Description = media.Description
+	}
+
+	if a.Episodes == 0 {
+		a.Episodes = media.Episodes
+	}
+
+	if a.Duration == 0 {
+		a.Duration = media.Duration
+	}
+
+	if a.Season == "" && media.Season != "" {
+		a.Season = models.AnimeSeason(strings.ToLower(media.Season))
+	}
+
+	if a.SeasonYear == 0 {
+		a.SeasonYear = media.SeasonYear
+	}
+
+	if len(a.Studios) == 0 {
+		for _, studio := range media.Studios.Nodes {
+			a.Studios = append(a.Studios, studio.Name)
+		}
+	}
+}
+
+func containsString(l []string, v string) bool {
+	for _, s := range l {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewAniListClient creates a new AniList client
+func NewAniListClient() *AniListClient {
+	return &AniListClient{
+		URL: AniListURL,
+	}
+}
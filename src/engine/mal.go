@@ -2,14 +2,14 @@ package engine
 
 import (
 	"aniapi-go/models"
-	"bytes"
-	"encoding/json"
+	"aniapi-go/utils"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -17,81 +17,271 @@ import (
 
 // MALSearch is the data definition of the MAL search engine
 type MALSearch struct {
-	scraper *Scraper
-	letter  string
-	page    int
+	scraper   *Scraper
+	anilist   *AniListClient
+	letter    string
+	page      int
+	lastMalID int
 }
 
-// ALQuery is the data definition of the AL query
-type ALQuery struct {
-	Query     string         `json:"query"`
-	Variables map[string]int `json:"variables"`
-}
+// MALCheckpointName is the name of the MAL crawl checkpoint
+const MALCheckpointName = "mal"
 
-// ALResponse is the data definition of the AL query response
-type ALResponse struct {
-	Data ALResponsePage `json:"data"`
-}
+var malLetters = strings.Split(".ABCDEFGHIJKLMNOPQRSTUVWXYZ", "")
 
-// ALResponsePage is the nested data definition of the AL query response
-type ALResponsePage struct {
-	Page ALResponseData `json:"Page"`
-}
+var malReset int32
 
-// ALResponseData is the nested data definition of the AL query response
-type ALResponseData struct {
-	Media []ALResponseMedia `json:"media"`
-}
+// MALMaxPageFailures is the number of consecutive failures on a listing page
+// after which the crawl is stopped, to be resumed from its checkpoint
+var MALMaxPageFailures = 5
 
-// ALResponseMedia is the nested data definition of the AL query response
-type ALResponseMedia struct {
-	ID int `json:"id"`
-}
+// MALPageRetryDelay is the delay before retrying a failed listing page
+var MALPageRetryDelay = 1 * time.Minute
 
 // Start initializes MAL search engine workflow
-func (m *MALSearch) Start() {
-	letters := strings.Split(".ABCDEFGHIJKLMNOPQRSTUVWXYZ", "")
+// The crawl resumes from the last stored checkpoint, if any
+// A letter ends only when its listing is over: failed pages are retried and,
+// if they keep failing, the crawl stops returning false
+func (m *MALSearch) Start() bool {
+	m.loadCheckpoint()
+	failures := 0
+
+	for i := m.letterIndex(); i < len(malLetters); i++ {
+		if m.letter != malLetters[i] {
+			m.letter = malLetters[i]
+			m.page = 0
+			m.lastMalID = 0
+		}
 
-	for _, m.letter = range letters {
 		s := false
-		m.page = 0
 
 		log.Printf("DOING LETTER %s AND PAGE %d", m.letter, m.page+1)
 
 		for s == false {
+			if atomic.CompareAndSwapInt32(&malReset, 1, 0) {
+				log.Printf("MAL CHECKPOINT RESET, RESTARTING FROM LETTER %s", malLetters[0])
+				m.letter = malLetters[0]
+				m.page = 0
+				m.lastMalID = 0
+				i = -1
+				break
+			}
+
+			m.saveCheckpoint()
+
 			uri := fmt.Sprintf("https://myanimelist.net/anime.php?letter=%s&show=%d", m.letter, m.page*50)
 			doc, err := m.scraper.ScrapeURL(uri)
 
 			if err != nil {
+				if !utils.IsFetchError(err, utils.FetchNotFound) {
+					failures++
+
+					if failures >= MALMaxPageFailures {
+						log.Printf("MAL CRAWL STOPPED ON LETTER %s AND PAGE %d AFTER %d FAILURES", m.letter, m.page+1, failures)
+						return false
+					}
+
+					time.Sleep(MALPageRetryDelay)
+					continue
+				}
+
 				s = true
 			} else {
+				var urls []string
+				failures = 0
+
 				doc.Find(".js-categories-seasonal.js-block-list.list tbody tr td .picSurround a").Each(func(_ int, s *goquery.Selection) {
 					animeURL, _ := s.Attr("href")
-					anime := m.scrapeElement(animeURL)
-
-					if anime != nil {
-						if anime.IsValid() {
-							anime.Save()
-
-							if anime.ID != 0 {
-								m.scraper.UpdateProcess(anime)
-							}
+					urls = append(urls, animeURL)
+				})
 
-							for _, module := range m.scraper.Modules {
-								module.Start(anime)
-							}
-						}
-					}
+				if len(urls) == 0 {
+					s = true
+				}
 
-					anime = nil
-				})
+				m.processPage(m.skipProcessed(urls))
 
 				doc = nil
 			}
 
 			m.page++
+			m.lastMalID = 0
 		}
 	}
+
+	err := models.ResetCheckpoint(MALCheckpointName)
+
+	if err != nil {
+		log.Printf("MAL CHECKPOINT NOT RESET: %s", err.Error())
+	}
+
+	return true
+}
+
+// processPage scrapes the page urls on the scraper pages pool
+// The checkpoint moves forward only when every previous url has been processed
+func (m *MALSearch) processPage(urls []string) {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	done := make([]bool, len(urls))
+	next := 0
+
+	for i, animeURL := range urls {
+		i, animeURL := i, animeURL
+
+		m.scraper.pagePool.Go(&wg, func() {
+			known, err := models.GetAnimeByMALID(getMALID(animeURL))
+
+			if err != nil || known.IsRefreshDue() {
+				m.Refresh(animeURL)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			done[i] = true
+
+			for next < len(urls) && done[next] {
+				m.lastMalID = getMALID(urls[next])
+				next++
+			}
+
+			m.saveCheckpoint()
+		})
+	}
+
+	wg.Wait()
+}
+
+// Refresh scrapes a single MAL anime page, saves it and runs every module on it
+// It returns false when the page could not be scraped or saved; when a
+// module fails the anime is queued, so that its modules are run again
+func (m *MALSearch) Refresh(animeURL string) bool {
+	anime := m.scrapeElement(animeURL)
+
+	if anime == nil {
+		return false
+	}
+
+	if anime.IsValid() {
+		if anime.Save() != nil {
+			return false
+		}
+
+		m.scraper.UpdateProcess(anime)
+
+		failed := 0
+
+		for _, result := range m.scraper.RunModules(context.Background(), anime) {
+			if result.Error != "" {
+				log.Printf("MODULE %s ON %s (%d) FAILED: %s", result.Module, anime.MainTitle, anime.ID, result.Error)
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			_, err := InsertItemInQueue(anime.ID, models.QueuePriorityNormal)
+
+			if err != nil {
+				log.Printf("QUEUE INSERT OF %s (%d) ERROR: %s", anime.MainTitle, anime.ID, err.Error())
+			}
+		}
+	}
+
+	return true
+}
+
+// GetMALURL returns the MAL page url of an anime
+func GetMALURL(malID int) string {
+	return fmt.Sprintf("https://myanimelist.net/anime/%d", malID)
+}
+
+// ResetMALCheckpoint removes the MAL crawl checkpoint
+// A running crawl restarts from the first letter before its next page
+func ResetMALCheckpoint() error {
+	err := models.ResetCheckpoint(MALCheckpointName)
+
+	if err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&malReset, 1)
+	return nil
+}
+
+func (m *MALSearch) loadCheckpoint() {
+	atomic.StoreInt32(&malReset, 0)
+
+	c, err := models.GetCheckpoint(MALCheckpointName)
+
+	if err != nil {
+		return
+	}
+
+	m.letter = c.Letter
+	m.page = c.Page
+	m.lastMalID = c.LastMalID
+
+	log.Printf("RESUMING MAL CRAWL FROM LETTER %s, PAGE %d AND MAL ID %d", m.letter, m.page+1, m.lastMalID)
+}
+
+// saveCheckpoint saves the crawl position, unless a reset is pending: the
+// pages still in flight would write the old position back
+func (m *MALSearch) saveCheckpoint() {
+	if atomic.LoadInt32(&malReset) == 1 {
+		return
+	}
+
+	c := &models.Checkpoint{
+		Name:      MALCheckpointName,
+		Letter:    m.letter,
+		Page:      m.page,
+		LastMalID: m.lastMalID,
+	}
+
+	err := c.Save()
+
+	if err != nil {
+		log.Printf("MAL CHECKPOINT NOT SAVED: %s", err.Error())
+	}
+}
+
+func (m *MALSearch) letterIndex() int {
+	for i, l := range malLetters {
+		if l == m.letter {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// skipProcessed returns the page urls following the last processed one
+// If the last processed one is not in the page every url is returned
+func (m *MALSearch) skipProcessed(urls []string) []string {
+	if m.lastMalID == 0 {
+		return urls
+	}
+
+	for i, u := range urls {
+		if getMALID(u) == m.lastMalID {
+			return urls[i+1:]
+		}
+	}
+
+	return urls
+}
+
+func getMALID(uri string) int {
+	parts := strings.Split(uri, "/")
+
+	if len(parts) < 5 {
+		return 0
+	}
+
+	id, _ := strconv.Atoi(parts[4])
+	return id
 }
 
 func (m *MALSearch) scrapeElement(uri string) *models.Anime {
@@ -105,6 +295,7 @@ func (m *MALSearch) scrapeElement(uri string) *models.Anime {
 	}
 
 	col := doc.Find("#content table tbody tr td").Eq(0).Find("div")
+	anime.MyAnimeListID = getMALID(uri)
 
 	title, _ := doc.Find("#contentWrapper .h1-title .title-name").Html()
 	anime.MainTitle = strings.Split(title, "<br/>")[0]
@@ -120,9 +311,9 @@ func (m *MALSearch) scrapeElement(uri string) *models.Anime {
 					titles := strings.Split(title, ", ")
 					anime.AlternativesTitle = append(anime.AlternativesTitle, titles...)
 					synonims = false
+				} else {
+					anime.AlternativesTitle = append(anime.AlternativesTitle, title)
 				}
-
-				anime.AlternativesTitle = append(anime.AlternativesTitle, title)
 			} else if t.Is("span") && title == "Synonyms:" {
 				synonims = true
 			}
@@ -133,9 +324,12 @@ func (m *MALSearch) scrapeElement(uri string) *models.Anime {
 		getItemByKeyword(s, anime)
 	})
 
-	anime.MyAnimeListID, _ = strconv.Atoi(strings.Split(uri, "/")[4])
+	anime.Description = strings.TrimSpace(doc.Find("[itemprop=description]").Text())
+	anime.Relations = getRelations(doc)
 
-	m.getAnilistData(anime)
+	m.scraper.anilistPool.Do(func() {
+		m.getAnilistData(anime)
+	})
 
 	elapsed := time.Since(start)
 	log.Printf("SCRAPED %s (%d|%d) IN %s", anime.MainTitle, anime.MyAnimeListID, anime.AniListID, elapsed)
@@ -144,99 +338,196 @@ func (m *MALSearch) scrapeElement(uri string) *models.Anime {
 }
 
 func (m *MALSearch) getAnilistData(a *models.Anime) {
-	al := &ALQuery{
-		Query: `query($idMal: Int) { 
-			Page(page: 1, perPage: 50) {
-				media(idMal: $idMal, type: ANIME) { 
-					id
-				}
+	media, err := m.anilist.FindByMALID(a.MyAnimeListID)
+
+	if err != nil {
+		log.Printf("ANILIST LOOKUP OF %s (%d) ERROR: %s", a.MainTitle, a.MyAnimeListID, err.Error())
+		return
+	}
+
+	if media != nil {
+		MergeAniListMedia(a, media)
+	}
+}
+
+// getItemByKeyword parses an information row of the MAL page, using its
+// dark_text label to recognize the field
+func getItemByKeyword(s *goquery.Selection, a *models.Anime) {
+	label := strings.TrimSuffix(strings.TrimSpace(s.Find("span.dark_text").First().Text()), ":")
+	value := getItemValue(s)
+
+	switch label {
+	case "Type":
+		a.Type = s.Find("a").Text()
+	case "Episodes":
+		a.Episodes, _ = strconv.Atoi(value)
+	case "Status":
+		a.SetStatus(value)
+	case "Aired":
+		parts := strings.SplitN(value, " to ", 2)
+
+		a.AiringStart, a.AiringStartPrecision = parseAiringDate(parts[0], a.MyAnimeListID)
+		a.AiringEnd, a.AiringEndPrecision = time.Time{}, utils.DatePrecisionUnknown
+
+		if len(parts) == 2 {
+			a.AiringEnd, a.AiringEndPrecision = parseAiringDate(parts[1], a.MyAnimeListID)
+		} else if a.AiringStartPrecision == utils.DatePrecisionDay {
+			a.AiringEnd, a.AiringEndPrecision = a.AiringStart, a.AiringStartPrecision
+		}
+	case "Premiered":
+		parts := strings.Fields(value)
+
+		if len(parts) == 2 {
+			a.Season = models.AnimeSeason(strings.ToLower(parts[0]))
+			a.SeasonYear, _ = strconv.Atoi(parts[1])
+		}
+	case "Broadcast":
+		if value != "Unknown" {
+			a.Broadcast = value
+		}
+	case "Producers":
+		a.Producers = getItemLinks(s, "/anime/producer/")
+	case "Studios":
+		a.Studios = getItemLinks(s, "/anime/producer/")
+	case "Source":
+		if value != "Unknown" {
+			a.Source = value
+		}
+	case "Genres", "Genre":
+		s.Contents().Each(func(_ int, t *goquery.Selection) {
+			if t.Is("a") {
+				a.Genres = append(a.Genres, t.Text())
 			}
-		}`,
+		})
+	case "Duration":
+		a.Duration = parseMALDuration(value)
+	case "Rating":
+		if value != "None" {
+			a.Rating = value
+		}
+	case "Score":
+		score, _ := strconv.ParseFloat(s.Find("span.score-label").Text(), 32)
+		a.Score = float32(score)
+	case "Ranked":
+		a.Rank = parseMALNumber(value)
+	case "Popularity":
+		a.Popularity = parseMALNumber(value)
+	case "Members":
+		a.Members = parseMALNumber(value)
 	}
-	al.Variables = make(map[string]int)
-	al.Variables["idMal"] = a.MyAnimeListID
+}
 
-	data, _ := json.Marshal(al)
+// getItemValue returns the text of an information row without its label
+// and footnotes
+func getItemValue(s *goquery.Selection) string {
+	value := ""
 
-	req, _ := http.NewRequest("POST", "https://graphql.anilist.co", bytes.NewBuffer(data))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	s.Contents().Each(func(_ int, t *goquery.Selection) {
+		if !t.Is("span.dark_text") && !t.Is("sup") {
+			value += t.Text()
+		}
+	})
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return strings.TrimSpace(value)
+}
+
+// getItemLinks returns the text of an information row links to the given path
+func getItemLinks(s *goquery.Selection, path string) []string {
+	var links []string
+
+	s.Find("a").Each(func(_ int, t *goquery.Selection) {
+		href, _ := t.Attr("href")
+
+		if strings.Contains(href, path) {
+			links = append(links, strings.TrimSpace(t.Text()))
+		}
+	})
+
+	return links
+}
+
+// parseAiringDate parses a side of the Aired range, logging unrecognized dates
+func parseAiringDate(value string, malID int) (time.Time, utils.DatePrecision) {
+	date, precision, err := utils.ParsePartialDate(value)
 
 	if err != nil {
-		log.Print(err.Error())
-		return
+		log.Printf("MAL %d AIRED DATE NOT PARSED: %s", malID, err.Error())
 	}
 
-	defer resp.Body.Close()
+	return date, precision
+}
+
+// parseMALNumber parses values like "#28" or "1,539,470", returning 0 for "N/A"
+func parseMALNumber(value string) int {
+	value = strings.Replace(strings.TrimPrefix(value, "#"), ",", "", -1)
+	number, _ := strconv.Atoi(strings.TrimSpace(value))
+
+	return number
+}
 
-	body, _ := ioutil.ReadAll(resp.Body)
+// parseMALDuration parses values like "24 min. per ep." or "1 hr. 55 min."
+// into minutes
+func parseMALDuration(value string) int {
+	minutes := 0
+	parts := strings.Fields(value)
 
-	result := &ALResponse{}
-	json.Unmarshal(body, &result)
+	for i := 1; i < len(parts); i++ {
+		n, err := strconv.Atoi(parts[i-1])
 
-	for _, media := range result.Data.Page.Media {
-		if a.AniListID == 0 || media.ID > a.AniListID {
-			a.AniListID = media.ID
-		} else {
+		if err != nil {
 			continue
 		}
+
+		if strings.HasPrefix(parts[i], "hr") {
+			minutes += n * 60
+		} else if strings.HasPrefix(parts[i], "min") {
+			minutes += n
+		}
 	}
+
+	return minutes
 }
 
-func getItemByKeyword(s *goquery.Selection, a *models.Anime) {
-	html, _ := s.Html()
+// getRelations parses the related anime table of the MAL page
+// Relations to other media, like mangas, are ignored
+func getRelations(doc *goquery.Document) []models.AnimeRelation {
+	var relations []models.AnimeRelation
 
-	if strings.Contains(html, "Type") {
-		a.Type = s.Find("a").Text()
-	} else if strings.Contains(html, "Score") {
-		score, _ := strconv.ParseFloat(s.Find("span.score-label").Text(), 32)
-		a.Score = float32(score)
-	} else if strings.Contains(html, "Status") {
-		status := strings.TrimSpace(strings.Split(s.Last().Text(), ":")[1])
-		a.SetStatus(status)
-	} else if strings.Contains(html, "Aired") {
-		s.Contents().Each(func(_ int, t *goquery.Selection) {
-			airing := strings.TrimSpace(t.Text())
+	doc.Find("table.anime_detail_related_anime tr").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		label := strings.TrimSuffix(strings.TrimSpace(cells.Eq(0).Text()), ":")
+		relation := strings.Replace(strings.ToLower(label), " ", "_", -1)
 
-			if !t.Is("span") && len(airing) != 0 {
-				parts := strings.Split(airing, "to")
+		cells.Eq(1).Find("a").Each(func(_ int, t *goquery.Selection) {
+			href, _ := t.Attr("href")
+			parts := strings.Split(href, "/")
 
-				i := -1
-				for _, part := range parts {
-					i++
-
-					part = strings.TrimSpace(part)
-					if part == "?" {
-						continue
-					}
+			if len(parts) < 3 || parts[1] != "anime" {
+				return
+			}
 
-					const form = "Jan 2, 2006"
-					date, _ := time.Parse(form, part)
+			malID, err := strconv.Atoi(parts[2])
 
-					if i == 0 {
-						a.AiringStart = date
-					} else {
-						a.AiringEnd = date
-					}
-				}
-			}
-		})
-	} else if strings.Contains(html, "Genres") {
-		s.Contents().Each(func(_ int, t *goquery.Selection) {
-			if t.Is("a") {
-				a.Genres = append(a.Genres, t.Text())
+			if err != nil {
+				return
 			}
+
+			relations = append(relations, models.AnimeRelation{
+				MyAnimeListID: malID,
+				Relation:      relation,
+				Title:         strings.TrimSpace(t.Text()),
+			})
 		})
-	}
+	})
+
+	return relations
 }
 
 // NewMALSearch creates a new MAL search engine
 func NewMALSearch(s *Scraper) *MALSearch {
 	return &MALSearch{
 		scraper: s,
+		anilist: NewAniListClient(),
 		page:    0,
 	}
 }
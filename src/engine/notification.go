@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"aniapi-go/models"
+	"log"
+	"sync"
+)
+
+// NotificationChannel is the socket channel of the notification events
+const NotificationChannel = "notification"
+
+// NotificationStreamBuffer is the number of events queued for a stream
+// Events are dropped for streams too slow to keep up
+var NotificationStreamBuffer = 64
+
+// NotificationStream receives the notification events matching its filters
+// Every event is received when both filters are empty
+type NotificationStream struct {
+	AnilistIDs []int
+	AnimeIDs   []int
+	Events     chan models.Notification
+}
+
+var notificationStreams = make(map[*NotificationStream]bool)
+var notificationStreamsMutex sync.RWMutex
+
+func init() {
+	models.AddNotificationListener(PublishNotification)
+}
+
+// PublishNotification sends a notification event on the notification socket
+// channel and to every matching stream
+func PublishNotification(n models.Notification) {
+	if n.Anime == nil {
+		n.Anime, _ = models.GetAnime(n.AnimeID)
+	}
+
+	msg := &SocketMessage{
+		AnilistID: n.AnilistID,
+		AnimeID:   n.AnimeID,
+		Channel:   NotificationChannel,
+		Data:      n,
+	}
+
+	go SocketWriteMessage(msg)
+
+	notificationStreamsMutex.RLock()
+	defer notificationStreamsMutex.RUnlock()
+
+	for s := range notificationStreams {
+		if !s.Matches(n) {
+			continue
+		}
+
+		select {
+		case s.Events <- n:
+		default:
+			log.Printf("NOTIFICATION STREAM TOO SLOW, EVENT %s (%d) DROPPED", n.Type, n.AnimeID)
+		}
+	}
+}
+
+// Matches checks if a notification matches the stream filters
+func (s *NotificationStream) Matches(n models.Notification) bool {
+	if len(s.AnimeIDs) == 0 && len(s.AnilistIDs) == 0 {
+		return true
+	}
+
+	return containsInt(s.AnimeIDs, n.AnimeID) || containsInt(s.AnilistIDs, n.AnilistID)
+}
+
+// Close stops the stream from receiving events
+func (s *NotificationStream) Close() {
+	notificationStreamsMutex.Lock()
+	defer notificationStreamsMutex.Unlock()
+
+	delete(notificationStreams, s)
+}
+
+// NewNotificationStream creates a stream receiving the notification events
+// published from now on, it must be closed when no longer read
+func NewNotificationStream(animeIDs []int, anilistIDs []int) *NotificationStream {
+	s := &NotificationStream{
+		AnilistIDs: anilistIDs,
+		AnimeIDs:   animeIDs,
+		Events:     make(chan models.Notification, NotificationStreamBuffer),
+	}
+
+	notificationStreamsMutex.Lock()
+	notificationStreams[s] = true
+	notificationStreamsMutex.Unlock()
+
+	return s
+}
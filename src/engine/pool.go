@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"sync"
+)
+
+// WorkerPool runs jobs on a fixed number of goroutines
+type WorkerPool struct {
+	jobs chan func()
+}
+
+// Do runs a job on the pool and waits for its completion
+func (p *WorkerPool) Do(job func()) {
+	done := make(chan bool)
+
+	p.jobs <- func() {
+		defer close(done)
+		job()
+	}
+
+	<-done
+}
+
+// Go runs a job on the pool and adds it to a wait group
+// It blocks while every worker is busy
+func (p *WorkerPool) Go(wg *sync.WaitGroup, job func()) {
+	wg.Add(1)
+
+	p.jobs <- func() {
+		defer wg.Done()
+		job()
+	}
+}
+
+func (p *WorkerPool) work() {
+	for job := range p.jobs {
+		job()
+	}
+}
+
+// NewWorkerPool creates a new pool with the given number of workers
+func NewWorkerPool(size int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+
+	p := &WorkerPool{
+		jobs: make(chan func()),
+	}
+
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+
+	return p
+}
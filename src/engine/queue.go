@@ -2,83 +2,220 @@ package engine
 
 import (
 	"aniapi-go/models"
+	"aniapi-go/utils"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
-// QueueItem is the queue's item data definition
-type QueueItem struct {
-	Anime         *models.Anime `json:"anime"`
-	InsertionDate time.Time     `json:"insertion_date"`
-	Running       bool          `json:"running"`
-	Completed     bool          `json:"completed"`
+// QueuePollInterval is the interval between runnable items lookups of an idle
+// queue worker, used to pick up retries whose delay has elapsed
+var QueuePollInterval = 5 * time.Second
+
+// QueueCancelWait is the time allowed to a worker to stop a cancelled item
+// before the cancel request is answered
+var QueueCancelWait = 10 * time.Second
+
+// QueueSocketLimit is the maximum number of active items sent to a new socket connection
+var QueueSocketLimit = 100
+
+var queueScraper *Scraper
+var queueWake = make(chan bool, 1)
+var queueRunning = make(map[int]*runningQueueItem)
+var queueRunningMutex sync.Mutex
+
+// runningQueueItem is an item running on this process, done is closed once
+// the item has been updated by its worker
+type runningQueueItem struct {
+	cancel context.CancelFunc
+	done   chan bool
 }
 
-var scraper *Scraper = NewScraper()
+// StartQueue starts the queue workers
+// Items left running by a previous process are released first, workers
+// count is read from the QUEUE_WORKERS env var
+func StartQueue(s *Scraper) {
+	queueScraper = s
+
+	released, err := models.ReleaseQueueItems()
+
+	if err != nil {
+		log.Printf("QUEUE RELEASE ERROR: %s", err.Error())
+	} else if released > 0 {
+		log.Printf("QUEUE RELEASED %d ITEMS LEFT RUNNING", released)
+	}
+
+	workers := utils.GetEnvInt("QUEUE_WORKERS", 2)
 
-// QueueItems are the queue items to elaborate progressively
-var QueueItems []*QueueItem
+	for i := 0; i < workers; i++ {
+		go queueWorker()
+	}
+}
 
-// StartQueue starts the queue's time-related elaboration process
-func StartQueue() {
+func queueWorker() {
 	for {
-		if len(QueueItems) > 0 {
-			item := QueueItems[0]
+		item, err := models.ClaimQueueItem()
 
-			if item != nil {
-				item.Running = true
+		if err == models.ErrNotFound {
+			select {
+			case <-queueWake:
+			case <-time.After(QueuePollInterval):
+			}
 
-				msg := &SocketMessage{
-					Channel: "queue",
-					Data:    item,
-				}
+			continue
+		}
 
-				go SocketWriteMessage(msg)
+		if err != nil {
+			log.Printf("QUEUE CLAIM ERROR: %s", err.Error())
+			time.Sleep(QueuePollInterval)
+			continue
+		}
 
-				for _, module := range scraper.Modules {
-					module.Start(item.Anime)
-				}
+		runQueueItem(item)
+	}
+}
 
-				item.Completed = true
+// runQueueItem runs every module on the anime of a claimed item
+// Failures are recorded on the item, which is retried or dead-lettered, while
+// an item cancelled during its run keeps the results of the modules
+func runQueueItem(item *models.QueueItem) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &runningQueueItem{cancel: cancel, done: make(chan bool)}
 
-				msg = &SocketMessage{
-					Channel: "queue",
-					Data:    item,
-				}
+	queueRunningMutex.Lock()
+	queueRunning[item.ID] = running
+	queueRunningMutex.Unlock()
 
-				go SocketWriteMessage(msg)
-			}
+	defer func() {
+		queueRunningMutex.Lock()
+		delete(queueRunning, item.ID)
+		queueRunningMutex.Unlock()
+
+		cancel()
+		close(running.done)
+	}()
+
+	anime, err := models.GetAnime(item.AnimeID)
+
+	if err == nil {
+		item.Anime = anime
+		writeQueueMessage(item)
+
+		err = runModules(ctx, item)
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("QUEUE ITEM %d (%d) CANCELLED WHILE RUNNING", item.ID, item.AnimeID)
+		err = item.Stop()
+	} else if err != nil {
+		log.Printf("QUEUE ITEM %d (%d) ATTEMPT %d FAILED: %s", item.ID, item.AnimeID, item.Attempts, err.Error())
+		err = item.Fail(err)
+	} else {
+		err = item.Complete()
+	}
+
+	if err != nil {
+		log.Printf("QUEUE ITEM %d (%d) NOT UPDATED: %s", item.ID, item.AnimeID, err.Error())
+	}
+
+	writeQueueMessage(item)
+}
+
+// runModules runs the scraper modules, failing when any module fails
+func runModules(ctx context.Context, item *models.QueueItem) error {
+	item.Results = queueScraper.RunModules(ctx, item.Anime)
+
+	var failed []string
 
-			QueueItems = QueueItems[1:]
+	for _, result := range item.Results {
+		if result.Error != "" {
+			failed = append(failed, result.Module)
 		}
+	}
 
-		time.Sleep(60 * time.Second)
+	if len(failed) > 0 {
+		return fmt.Errorf("modules failed: %s", strings.Join(failed, ", "))
 	}
+
+	return nil
 }
 
-// InsertItemInQueue inserts a new item at the bottom of the queue
-func InsertItemInQueue(item *QueueItem) {
-	QueueItems = append(QueueItems, item)
+// CancelRunningQueueItem stops the modules running for a queue item, which
+// is then marked as cancelled by its worker
+// The returned channel is closed once the worker has updated the item, it
+// returns false when the item is not running on this process
+func CancelRunningQueueItem(id int) (<-chan bool, bool) {
+	queueRunningMutex.Lock()
+	defer queueRunningMutex.Unlock()
 
-	msg := &SocketMessage{
-		Channel: "queue",
-		Data:    item,
+	running, ok := queueRunning[id]
+
+	if !ok {
+		return nil, false
 	}
 
-	go SocketWriteMessage(msg)
+	running.cancel()
+
+	return running.done, true
 }
 
-// NewQueueItem returns a new queue's item
-func NewQueueItem(animeID int) *QueueItem {
-	anime, err := models.GetAnime(animeID)
+// InsertItemInQueue queues an anime and wakes an idle worker
+// An anime already queued keeps its item, with the highest priority
+func InsertItemInQueue(animeID int, priority int) (*models.QueueItem, error) {
+	item, err := models.EnqueueAnime(animeID, priority)
 
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	select {
+	case queueWake <- true:
+	default:
+	}
+
+	writeQueueMessage(item)
+
+	return item, nil
+}
+
+// GetActiveQueueItems returns the running and pending queue items, with their anime
+func GetActiveQueueItems() []models.QueueItem {
+	var items []models.QueueItem
+
+	page := &utils.PageInfo{
+		Size: QueueSocketLimit,
 	}
 
-	return &QueueItem{
-		Anime:         anime,
-		InsertionDate: time.Now(),
-		Running:       false,
-		Completed:     false,
+	for _, status := range []models.QueueStatus{models.QueueStatusRunning, models.QueueStatusPending} {
+		found, err := models.FindQueueItems(status, page)
+
+		if err != nil {
+			log.Printf("QUEUE LOOKUP ERROR: %s", err.Error())
+		}
+
+		for i := range found {
+			found[i].Anime, _ = models.GetAnime(found[i].AnimeID)
+		}
+
+		items = append(items, found...)
 	}
+
+	return items
+}
+
+func writeQueueMessage(item *models.QueueItem) {
+	msg := &SocketMessage{
+		AnimeID: item.AnimeID,
+		Channel: "queue",
+		Data:    *item,
+	}
+
+	if item.Anime != nil {
+		msg.AnilistID = item.Anime.AniListID
+	}
+
+	go SocketWriteMessage(msg)
 }
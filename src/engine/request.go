@@ -1,6 +1,11 @@
 package engine
 
-import "net/http"
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
 
 // Request is a wrapper to http.Request
 type Request struct {
@@ -9,3 +14,22 @@ type Request struct {
 	Query              map[string]string
 	NeedSingleResource bool
 }
+
+// IsAdmin checks if the request is authorized by the ADMIN_TOKEN env var,
+// sent as a bearer token
+// Every request is refused when the env var is not set
+func (r *Request) IsAdmin() bool {
+	token := os.Getenv("ADMIN_TOKEN")
+
+	if token == "" {
+		return false
+	}
+
+	auth := r.Data.Header.Get("Authorization")
+
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1
+}
@@ -62,6 +62,45 @@ func (res *Response) WriteJSONError(status int, body string) {
 	res.Write(status, "{ \"error\": \""+body+"\"}")
 }
 
+// StartEventStream is used to setup the response to stream Server-Sent Events
+// It returns false when the writer can not stream
+func (res *Response) StartEventStream() bool {
+	flusher, ok := res.Writer.(http.Flusher)
+
+	if !ok {
+		return false
+	}
+
+	res.Status = http.StatusOK
+	res.Writer.Header().Set("Content-Type", "text/event-stream")
+	res.Writer.Header().Set("Cache-Control", "no-cache")
+	res.Writer.Header().Set("Connection", "keep-alive")
+	res.Writer.Header().Set("X-Accel-Buffering", "no")
+	res.Writer.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return true
+}
+
+// WriteEvent writes a Server-Sent Event to a started event stream
+// An empty event writes a comment, used to keep the connection alive
+func (res *Response) WriteEvent(id string, event string, data string) error {
+	var err error
+
+	if event == "" {
+		_, err = fmt.Fprint(res.Writer, ": keep-alive\n\n")
+	} else {
+		_, err = fmt.Fprintf(res.Writer, "id: %s\nevent: %s\ndata: %s\n\n", id, event, data)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	res.Writer.(http.Flusher).Flush()
+	return nil
+}
+
 // WriteHTML is used to setup an HTML file response content
 func (res *Response) WriteHTML(filePath string) {
 	html, err := ioutil.ReadFile(filePath)
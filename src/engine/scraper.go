@@ -4,10 +4,12 @@ import (
 	"aniapi-go/models"
 	"aniapi-go/modules"
 	"aniapi-go/utils"
-	"errors"
+	"context"
+	"fmt"
 	"log"
-	"net/http"
+	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -15,9 +17,11 @@ import (
 
 // Scraper is the data definition of the scraper engine
 type Scraper struct {
-	Modules []modules.Module
-	running bool
-	start   time.Time
+	pagePool    *WorkerPool
+	anilistPool *WorkerPool
+	modulePool  *WorkerPool
+	running     bool
+	start       time.Time
 }
 
 // ScraperInfo is the data definition of the scraper process status
@@ -27,20 +31,184 @@ type ScraperInfo struct {
 	Memory    uint64        `json:"memory"`
 }
 
+// CrawlInterval is the interval between full MAL crawls, used to discover new animes
+var CrawlInterval = 24 * time.Hour
+
+// CrawlRetryInterval is the interval before resuming a stopped MAL crawl
+var CrawlRetryInterval = 1 * time.Hour
+
+// ModulesDir is the default directory of declarative module definitions
+var ModulesDir = "modules.d"
+
+// ModulesConfig is the default path of the modules config file
+var ModulesConfig = "modules.yml"
+
+// RefreshPollInterval is the interval between due animes lookups
+var RefreshPollInterval = 1 * time.Minute
+
 // Start initializes scraper engine workflow
+// Known animes are re-scraped when their refresh is due, while a full
+// MAL crawl runs every CrawlInterval to discover new ones
 func (s *Scraper) Start() {
+	var lastCrawl time.Time
+
+	for {
+		if time.Since(lastCrawl) >= CrawlInterval {
+			if s.crawl() {
+				lastCrawl = time.Now()
+			} else {
+				lastCrawl = time.Now().Add(CrawlRetryInterval - CrawlInterval)
+			}
+		}
+
+		s.refreshDue()
+
+		time.Sleep(RefreshPollInterval)
+	}
+}
+
+func (s *Scraper) crawl() bool {
 	s.running = true
 	s.start = time.Now()
 	go printMemoryUsed(s)
 
 	mal := NewMALSearch(s)
-	mal.Start()
+	completed := mal.Start()
 
 	s.running = false
 	s.UpdateProcess(nil)
 
-	time.Sleep(6 * time.Hour)
-	s.Start()
+	return completed
+}
+
+// refreshDue re-scrapes every anime whose refresh is due
+func (s *Scraper) refreshDue() {
+	mal := NewMALSearch(s)
+
+	for {
+		animes, err := models.FindDueAnimes(50)
+
+		if err != nil {
+			log.Printf("DUE ANIMES LOOKUP ERROR: %s", err.Error())
+			return
+		}
+
+		if len(animes) == 0 {
+			return
+		}
+
+		for _, a := range animes {
+			log.Printf("REFRESHING %s (%d|%d)", a.MainTitle, a.ID, a.MyAnimeListID)
+
+			if a.MyAnimeListID != 0 && mal.Refresh(GetMALURL(a.MyAnimeListID)) {
+				updated, err := models.GetAnime(a.ID)
+
+				if err == nil && !updated.IsRefreshDue() {
+					continue
+				}
+			}
+
+			a.Postpone(models.RefreshRetry)
+		}
+	}
+}
+
+// ModuleTimeout is the maximum duration of a module run on an anime
+var ModuleTimeout = 30 * time.Minute
+
+// ModuleRunInfo is the data definition of a module run message
+type ModuleRunInfo struct {
+	AnimeID int                 `json:"anime_id"`
+	Result  models.ModuleResult `json:"result"`
+}
+
+// RunModules runs every enabled module on an anime using the modules pool
+// It returns when every module has completed or the context is done, with a
+// result for each module; results are broadcasted on the module channel too
+func (s *Scraper) RunModules(ctx context.Context, anime *models.Anime) []models.ModuleResult {
+	var wg sync.WaitGroup
+
+	names := modules.Enabled()
+	results := make([]models.ModuleResult, len(names))
+	ran := make([]bool, len(names))
+
+	for i, name := range names {
+		i, name := i, name
+
+		s.modulePool.Go(&wg, func() {
+			results[i], ran[i] = modules.Run(ctx, name, func(m modules.Module) models.ModuleResult {
+				return runModule(ctx, name, m, anime)
+			})
+
+			if ran[i] {
+				writeModuleMessage(anime, results[i])
+			}
+		})
+	}
+
+	wg.Wait()
+
+	completed := make([]models.ModuleResult, 0, len(results))
+
+	for i, result := range results {
+		if ran[i] {
+			completed = append(completed, result)
+		}
+	}
+
+	return completed
+}
+
+// runModule runs a module on an anime within ModuleTimeout, turning a module
+// panic into an error
+func runModule(ctx context.Context, name string, module modules.Module, anime *models.Anime) (result models.ModuleResult) {
+	result.Module = name
+	result.StartDate = time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("module panic: %v", r)
+			log.Printf("MODULE %s ON %s (%d) PANIC: %v", result.Module, anime.MainTitle, anime.ID, r)
+		}
+
+		result.EndDate = time.Now()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, ModuleTimeout)
+	defer cancel()
+
+	res, err := module.Start(ctx, anime)
+
+	if res != nil {
+		result.Confidence = res.Confidence
+		result.Episodes = res.Episodes
+		result.Errors = res.Errors
+		result.Match = res.Match
+		result.NewEpisodes = res.New
+		result.UpdatedEpisodes = res.Updated
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	result.Matchings = len(module.GetMatches(anime.ID))
+
+	return result
+}
+
+func writeModuleMessage(anime *models.Anime, result models.ModuleResult) {
+	msg := &SocketMessage{
+		AnimeID:   anime.ID,
+		AnilistID: anime.AniListID,
+		Channel:   "module",
+		Data: &ModuleRunInfo{
+			AnimeID: anime.ID,
+			Result:  result,
+		},
+	}
+
+	go SocketWriteMessage(msg)
 }
 
 // UpdateProcess updates scraper process
@@ -64,42 +232,24 @@ func (s *Scraper) UpdateProcess(anime *models.Anime) {
 		Data:    ss,
 	}
 
+	if anime != nil {
+		msg.AnimeID = anime.ID
+		msg.AnilistID = anime.AniListID
+	}
+
 	go SocketWriteMessage(msg)
 }
 
 // ScrapeURL tries to parse an URI HTML
+// Temporary failures are retried by the shared fetcher
 func (s *Scraper) ScrapeURL(url string) (*goquery.Document, error) {
-	transport := &http.Transport{
-		Proxy: utils.GetBestProxy,
-	}
-
-	client := http.Client{
-		Transport: transport,
-	}
-
-	req, _ := http.NewRequest("GET", url, nil)
-	resp, err := client.Do(req)
+	doc, err := utils.DefaultFetcher.GetDocument(url)
 
 	if err != nil {
 		log.Printf("URL (%s) REQUEST ERROR: %s", url, err.Error())
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		err = errors.New(resp.Status)
-		log.Printf("URL (%s) REQUEST ERROR: %s", url, err.Error())
-		return nil, errors.New(resp.Status)
-	}
-
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-
-	if err != nil {
-		log.Printf("URL (%s) READING ERROR: %s", url, err.Error())
-		return nil, err
-	}
-
 	return doc, nil
 }
 
@@ -114,12 +264,28 @@ func printMemoryUsed(s *Scraper) {
 }
 
 // NewScraper creates a new scraper engine
+// Pools sizes are read from the SCRAPER_WORKERS, ANILIST_WORKERS and
+// MODULE_WORKERS env vars, modules are set up from the MODULES_CONFIG file
+// and the MODULES_DIR declarative definitions
 func NewScraper() *Scraper {
+	config := os.Getenv("MODULES_CONFIG")
+
+	if config == "" {
+		config = ModulesConfig
+	}
+
+	dir := os.Getenv("MODULES_DIR")
+
+	if dir == "" {
+		dir = ModulesDir
+	}
+
+	modules.Init(config, dir)
+
 	return &Scraper{
-		running: false,
-		Modules: []modules.Module{
-			modules.NewDreamsub(),
-			//modules.NewGogoanime(),
-		},
+		pagePool:    NewWorkerPool(utils.GetEnvInt("SCRAPER_WORKERS", 4)),
+		anilistPool: NewWorkerPool(utils.GetEnvInt("ANILIST_WORKERS", 2)),
+		modulePool:  NewWorkerPool(utils.GetEnvInt("MODULE_WORKERS", 4)),
+		running:     false,
 	}
 }
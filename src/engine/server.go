@@ -1,7 +1,6 @@
 package engine
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
 	"regexp"
@@ -20,9 +19,12 @@ type Route struct {
 }
 
 // SocketMessage is the standard socket message type
+// AnimeID and AnilistID are used to match the subscriptions filters only
 type SocketMessage struct {
-	Channel string      `json:"channel"`
-	Data    interface{} `json:"data"`
+	AnilistID int         `json:"-"`
+	AnimeID   int         `json:"-"`
+	Channel   string      `json:"channel"`
+	Data      interface{} `json:"data"`
 }
 
 // Server is a custom router
@@ -31,7 +33,6 @@ type Server struct {
 	DefaultRoute FHandler
 }
 
-var socketConnections []*websocket.Conn
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -106,56 +107,22 @@ func (s *Server) Handle(pattern string, handler FHandler) {
 	s.routes = append(s.routes, route)
 }
 
-// OnSocketConnStart adds a new socket connection to the alive connections pool
-func OnSocketConnStart(w *Response, r *Request) {
+// OnSocketConnStart upgrades a request to a socket connection and adds it
+// to the hub, it returns nil when the upgrade fails
+func OnSocketConnStart(w *Response, r *Request) *SocketClient {
 	conn, err := upgrader.Upgrade(w.Writer, r.Data, nil)
 
 	if err != nil {
 		log.Printf("SOCKET ERROR: %s", err.Error())
-		return
-	}
-
-	duplicate := false
-
-	for _, c := range socketConnections {
-		if conn.RemoteAddr().String() == c.RemoteAddr().String() {
-			duplicate = true
-		}
+		return nil
 	}
 
-	if duplicate == false {
-		socketConnections = append(socketConnections, conn)
-	}
+	return socketHub.Register(conn)
 }
 
-// SocketWriteMessage writes a message to all alive connections
+// SocketWriteMessage writes a message to every connection subscribed to its channel
 func SocketWriteMessage(msg *SocketMessage) {
-	var toDelete []int
-
-	json, err := json.Marshal(msg)
-
-	if err != nil {
-		log.Printf("SOCKET JSON ERROR: %s", err.Error())
-		return
-	}
-
-	for i, s := range socketConnections {
-		err := s.WriteMessage(1, []byte(json))
-
-		if err != nil {
-			log.Printf("SOCKET WRITE ERROR: %s", err.Error())
-			toDelete = append(toDelete, i)
-		}
-	}
-
-	for j := len(toDelete) - 1; j >= 0; j-- {
-		i := toDelete[j]
-
-		length := len(socketConnections) - 1
-		socketConnections[i] = socketConnections[length]
-		socketConnections[length] = nil
-		socketConnections = socketConnections[:length]
-	}
+	socketHub.Broadcast(msg)
 }
 
 // NewServer creates a new application router
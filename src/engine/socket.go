@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SocketWriteWait is the time allowed to write a message to a connection
+var SocketWriteWait = 10 * time.Second
+
+// SocketPongWait is the time allowed to read the next pong from a connection
+var SocketPongWait = 60 * time.Second
+
+// SocketPingPeriod is the interval between pings, must be lower than SocketPongWait
+var SocketPingPeriod = (SocketPongWait * 9) / 10
+
+// SocketMaxMessageSize is the maximum size of a message read from a connection
+var SocketMaxMessageSize int64 = 4096
+
+// SocketSendBuffer is the number of messages queued for a connection
+// Connections too slow to keep up are closed
+var SocketSendBuffer = 256
+
+// SocketDefaultChannels are the channels of clients which never subscribed
+var SocketDefaultChannels = []string{"scraper", "queue"}
+
+// SocketChannel is the channel of the hub replies to the clients commands
+const SocketChannel = "socket"
+
+// SocketCommand is a message sent by a client to manage its subscriptions
+// AnimeIDs and AnilistIDs restrict a subscription to the messages of some
+// animes, every message of the channel is received when both are empty
+type SocketCommand struct {
+	Action     string `json:"action"`
+	AnilistIDs []int  `json:"anilist_ids"`
+	AnimeIDs   []int  `json:"anime_ids"`
+	Channel    string `json:"channel"`
+}
+
+// SocketSubscription is a client subscription to a channel
+type SocketSubscription struct {
+	AnilistIDs []int  `json:"anilist_ids"`
+	AnimeIDs   []int  `json:"anime_ids"`
+	Channel    string `json:"channel"`
+}
+
+// SocketReply is the data of the hub replies to the clients commands
+type SocketReply struct {
+	Error         string               `json:"error,omitempty"`
+	Subscriptions []SocketSubscription `json:"subscriptions"`
+}
+
+// SocketClient is a socket connection registered to the hub
+// Only its writer goroutine writes to the connection
+type SocketClient struct {
+	conn          *websocket.Conn
+	hub           *SocketHub
+	mutex         sync.RWMutex
+	send          chan []byte
+	subscriptions map[string]SocketSubscription
+}
+
+// SocketHub keeps the alive socket connections and dispatches messages to them
+type SocketHub struct {
+	clients map[*SocketClient]bool
+	mutex   sync.RWMutex
+}
+
+var socketHub = NewSocketHub()
+
+// Register adds a connection to the hub and starts its reader and writer goroutines
+func (h *SocketHub) Register(conn *websocket.Conn) *SocketClient {
+	c := &SocketClient{
+		conn:          conn,
+		hub:           h,
+		send:          make(chan []byte, SocketSendBuffer),
+		subscriptions: make(map[string]SocketSubscription),
+	}
+
+	for _, channel := range SocketDefaultChannels {
+		c.subscriptions[channel] = SocketSubscription{Channel: channel}
+	}
+
+	h.mutex.Lock()
+	h.clients[c] = true
+	h.mutex.Unlock()
+
+	go c.writePump()
+	go c.readPump()
+
+	return c
+}
+
+// Unregister removes a client from the hub and stops its writer goroutine
+func (h *SocketHub) Unregister(c *SocketClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.send)
+	}
+}
+
+// Broadcast queues a message on every client subscribed to its channel
+func (h *SocketHub) Broadcast(msg *SocketMessage) {
+	data, err := json.Marshal(msg)
+
+	if err != nil {
+		log.Printf("SOCKET JSON ERROR: %s", err.Error())
+		return
+	}
+
+	var slow []*SocketClient
+
+	h.mutex.RLock()
+
+	for c := range h.clients {
+		if !c.IsSubscribed(msg) {
+			continue
+		}
+
+		select {
+		case c.send <- data:
+		default:
+			slow = append(slow, c)
+		}
+	}
+
+	h.mutex.RUnlock()
+
+	for _, c := range slow {
+		log.Printf("SOCKET %s TOO SLOW, CLOSING", c.conn.RemoteAddr().String())
+		h.Unregister(c)
+	}
+}
+
+// Count returns the number of alive connections
+func (h *SocketHub) Count() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return len(h.clients)
+}
+
+// IsSubscribed checks if a message matches one of the client subscriptions
+func (c *SocketClient) IsSubscribed(msg *SocketMessage) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	sub, ok := c.subscriptions[msg.Channel]
+
+	if !ok {
+		return false
+	}
+
+	if len(sub.AnimeIDs) == 0 && len(sub.AnilistIDs) == 0 {
+		return true
+	}
+
+	return (msg.AnimeID != 0 && containsInt(sub.AnimeIDs, msg.AnimeID)) ||
+		(msg.AnilistID != 0 && containsInt(sub.AnilistIDs, msg.AnilistID))
+}
+
+// WriteMessage queues a message for the client only, ignoring its subscriptions
+func (c *SocketClient) WriteMessage(msg *SocketMessage) {
+	data, err := json.Marshal(msg)
+
+	if err != nil {
+		log.Printf("SOCKET JSON ERROR: %s", err.Error())
+		return
+	}
+
+	c.hub.mutex.RLock()
+	defer c.hub.mutex.RUnlock()
+
+	if _, ok := c.hub.clients[c]; !ok {
+		return
+	}
+
+	select {
+	case c.send <- data:
+	default:
+	}
+}
+
+// Subscriptions returns the client subscriptions
+func (c *SocketClient) Subscriptions() []SocketSubscription {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	subs := make([]SocketSubscription, 0, len(c.subscriptions))
+
+	for _, sub := range c.subscriptions {
+		subs = append(subs, sub)
+	}
+
+	return subs
+}
+
+// handleCommand applies a subscription command sent by the client
+// The default channels are dropped on the first subscription, so clients
+// receive the channels they asked for only
+func (c *SocketClient) handleCommand(cmd *SocketCommand) string {
+	if cmd.Channel == "" {
+		return "missing channel"
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch cmd.Action {
+	case "subscribe":
+		if c.isDefault() {
+			c.subscriptions = make(map[string]SocketSubscription)
+		}
+
+		c.subscriptions[cmd.Channel] = SocketSubscription{
+			AnilistIDs: cmd.AnilistIDs,
+			AnimeIDs:   cmd.AnimeIDs,
+			Channel:    cmd.Channel,
+		}
+	case "unsubscribe":
+		delete(c.subscriptions, cmd.Channel)
+	default:
+		return "unknown action " + cmd.Action
+	}
+
+	return ""
+}
+
+func (c *SocketClient) isDefault() bool {
+	if len(c.subscriptions) != len(SocketDefaultChannels) {
+		return false
+	}
+
+	for _, channel := range SocketDefaultChannels {
+		sub, ok := c.subscriptions[channel]
+
+		if !ok || len(sub.AnimeIDs) > 0 || len(sub.AnilistIDs) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readPump reads the client commands and pongs until the connection fails
+func (c *SocketClient) readPump() {
+	defer func() {
+		c.hub.Unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(SocketMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(SocketPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(SocketPongWait))
+	})
+
+	for {
+		cmd := &SocketCommand{}
+		err := c.conn.ReadJSON(cmd)
+
+		if err != nil {
+			// the frame has been read already, so the connection stays usable
+			switch err.(type) {
+			case *json.SyntaxError, *json.UnmarshalTypeError:
+				c.reply("invalid command")
+				continue
+			}
+
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("SOCKET READ ERROR: %s", err.Error())
+			}
+
+			return
+		}
+
+		c.reply(c.handleCommand(cmd))
+	}
+}
+
+func (c *SocketClient) reply(e string) {
+	c.WriteMessage(&SocketMessage{
+		Channel: SocketChannel,
+		Data: &SocketReply{
+			Error:         e,
+			Subscriptions: c.Subscriptions(),
+		},
+	})
+}
+
+// writePump writes the queued messages and the pings to the connection
+func (c *SocketClient) writePump() {
+	ticker := time.NewTicker(SocketPingPeriod)
+
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(SocketWriteWait))
+
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			err := c.conn.WriteMessage(websocket.TextMessage, data)
+
+			if err != nil {
+				log.Printf("SOCKET WRITE ERROR: %s", err.Error())
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(SocketWriteWait))
+
+			err := c.conn.WriteMessage(websocket.PingMessage, nil)
+
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func containsInt(l []int, v int) bool {
+	for _, i := range l {
+		if i == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewSocketHub creates a new empty socket hub
+func NewSocketHub() *SocketHub {
+	return &SocketHub{
+		clients: make(map[*SocketClient]bool),
+	}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestSocketInvalidCommand checks that malformed and mistyped commands are
+// answered with an error, keeping the connection usable
+func TestSocketInvalidCommand(t *testing.T) {
+	hub := NewSocketHub()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err == nil {
+			hub.Register(conn)
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+
+	if err != nil {
+		t.Fatalf("connection failed: %s", err.Error())
+	}
+
+	defer conn.Close()
+
+	commands := []struct {
+		command string
+		error   string
+	}{
+		{`not a command`, "invalid command"},
+		{`{"action":"subscribe","channel":"module","anime_ids":"12"}`, "invalid command"},
+		{`{"action":"subscribe","channel":"module","anime_ids":[12]}`, ""},
+	}
+
+	for _, c := range commands {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(c.command)); err != nil {
+			t.Fatalf("%s not sent: %s", c.command, err.Error())
+		}
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+
+		if err != nil {
+			t.Fatalf("%s not answered: %s", c.command, err.Error())
+		}
+
+		reply := struct {
+			Channel string      `json:"channel"`
+			Data    SocketReply `json:"data"`
+		}{}
+
+		if err := json.Unmarshal(data, &reply); err != nil {
+			t.Fatalf("reply %s not parsed: %s", data, err.Error())
+		}
+
+		if reply.Channel != SocketChannel || reply.Data.Error != c.error {
+			t.Errorf("%s: expected error %q, got %s", c.command, c.error, data)
+		}
+	}
+}
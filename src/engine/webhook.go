@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WebhookPayload is the JSON body posted to the webhooks
+type WebhookPayload struct {
+	Event        string              `json:"event"`
+	Notification models.Notification `json:"notification"`
+	WebhookID    int                 `json:"webhook_id"`
+}
+
+// WebhookPollInterval is the interval between due deliveries lookups
+var WebhookPollInterval = 10 * time.Second
+
+// WebhookTimeout is the time allowed to a receiver to answer a delivery
+var WebhookTimeout = 10 * time.Second
+
+// WebhookUserAgent is the user agent of the deliveries requests
+const WebhookUserAgent = "AniAPI-Webhook/1.0"
+
+// webhookClient refuses to connect to non public addresses, also when a
+// webhook host resolves to one after its creation
+var webhookClient = &http.Client{
+	Timeout: WebhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Control: utils.PublicDialControl,
+			Timeout: WebhookTimeout,
+		}).DialContext,
+	},
+}
+var webhookWake = make(chan bool, 1)
+
+func init() {
+	models.AddNotificationListener(DispatchWebhooks)
+}
+
+// DispatchWebhooks creates a delivery of a notification for every matching webhook
+func DispatchWebhooks(n models.Notification) {
+	webhooks, err := models.FindWebhooks()
+
+	if err != nil {
+		log.Printf("WEBHOOKS LOOKUP ERROR: %s", err.Error())
+		return
+	}
+
+	if n.Anime == nil {
+		n.Anime, _ = models.GetAnime(n.AnimeID)
+	}
+
+	created := false
+
+	for _, w := range webhooks {
+		if !w.Matches(n) {
+			continue
+		}
+
+		payload, err := json.Marshal(&WebhookPayload{
+			Event:        NotificationChannel,
+			Notification: n,
+			WebhookID:    w.ID,
+		})
+
+		if err != nil {
+			log.Printf("WEBHOOK %d JSON ERROR: %s", w.ID, err.Error())
+			continue
+		}
+
+		_, err = models.NewWebhookDelivery(w.ID, string(payload))
+
+		if err != nil {
+			log.Printf("WEBHOOK %d DELIVERY NOT CREATED: %s", w.ID, err.Error())
+			continue
+		}
+
+		created = true
+	}
+
+	if created {
+		select {
+		case webhookWake <- true:
+		default:
+		}
+	}
+}
+
+// StartWebhooks starts delivering the due webhook deliveries
+// Deliveries run on a pool sized by the WEBHOOK_WORKERS env var
+func StartWebhooks() {
+	pool := NewWorkerPool(utils.GetEnvInt("WEBHOOK_WORKERS", 4))
+
+	for {
+		deliveries, err := models.FindDueWebhookDeliveries(100)
+
+		if err != nil {
+			log.Printf("DUE DELIVERIES LOOKUP ERROR: %s", err.Error())
+		}
+
+		if len(deliveries) == 0 {
+			select {
+			case <-webhookWake:
+			case <-time.After(WebhookPollInterval):
+			}
+
+			continue
+		}
+
+		var wg sync.WaitGroup
+
+		for i := range deliveries {
+			d := &deliveries[i]
+
+			pool.Go(&wg, func() {
+				deliverWebhook(d)
+			})
+		}
+
+		wg.Wait()
+	}
+}
+
+// deliverWebhook posts a delivery payload and records the attempt outcome
+// Any 2xx status is a success, deliveries of removed webhooks fail at once
+func deliverWebhook(d *models.WebhookDelivery) {
+	w, err := models.GetWebhook(d.WebhookID)
+
+	if err == models.ErrNotFound {
+		d.Attempts = models.WebhookMaxAttempts
+		err = d.Fail(0, fmt.Errorf("webhook removed"))
+	} else if err == nil {
+		status, postErr := postWebhook(w, d)
+
+		if postErr != nil {
+			log.Printf("WEBHOOK %d DELIVERY %d ATTEMPT %d FAILED: %s", w.ID, d.ID, d.Attempts+1, postErr.Error())
+			err = d.Fail(status, postErr)
+		} else {
+			err = d.Delivered(status)
+		}
+	}
+
+	if err != nil {
+		log.Printf("WEBHOOK %d DELIVERY %d NOT UPDATED: %s", d.WebhookID, d.ID, err.Error())
+	}
+}
+
+func postWebhook(w *models.Webhook, d *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewBufferString(d.Payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", WebhookUserAgent)
+	req.Header.Set("X-AniAPI-Event", NotificationChannel)
+	req.Header.Set("X-AniAPI-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-AniAPI-Signature", "sha256="+SignWebhookPayload(w.Secret, d.Payload))
+
+	resp, err := webhookClient.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of a payload with a webhook secret
+// Receivers compare it with the X-AniAPI-Signature header, after the "sha256=" prefix
+func SignWebhookPayload(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -17,4 +17,5 @@ require (
 	go.mongodb.org/mongo-driver v1.3.3
 	golang.org/x/net v0.0.0-20200513185701-a91f0712d120 // indirect
 	google.golang.org/appengine v1.6.6 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
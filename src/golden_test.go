@@ -0,0 +1,193 @@
+package main
+
+import (
+	"aniapi-go/engine"
+	"aniapi-go/models"
+	"aniapi-go/modules"
+	"aniapi-go/utils"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// goldenCase is the expected result of scraping a MAL anime and running
+// every module on it
+type goldenCase struct {
+	Anime    *models.Anime    `json:"anime"`
+	Episodes []models.Episode `json:"episodes"`
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files")
+
+// TestGolden scrapes the MAL animes having a golden file using the fixtures
+// and checks the parsed models against the golden files
+// With -update the golden files are rewritten, for the comma separated
+// GOLDEN_IDS MAL ids too; FIXTURES_MODE=record fetches and saves the
+// fixtures as well
+// The committed fixtures are hand-written stubs of the real pages, so the
+// golden files check the parsers against the stubs markup only
+func TestGolden(t *testing.T) {
+	dir := os.Getenv("FIXTURES_DIR")
+
+	if dir == "" {
+		dir = "fixtures"
+	}
+
+	mode := utils.FixtureMode(os.Getenv("FIXTURES_MODE"))
+
+	if mode != utils.FixtureRecord {
+		mode = utils.FixtureReplay
+	}
+
+	goldenDir := filepath.Join(dir, "golden")
+	malIDs, err := getGoldenIDs(goldenDir)
+
+	if err != nil {
+		t.Fatalf("golden files not read: %s", err.Error())
+	}
+
+	if *updateGolden && os.Getenv("GOLDEN_IDS") != "" {
+		for _, value := range strings.Split(os.Getenv("GOLDEN_IDS"), ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(value))
+
+			if err != nil {
+				t.Fatalf("invalid MAL id %s", value)
+			}
+
+			malIDs = append(malIDs, id)
+		}
+	}
+
+	if len(malIDs) == 0 {
+		t.Skip("no golden files")
+	}
+
+	models.SetStore(models.NewMemoryStore())
+	transport := utils.UseFixtures(dir, mode)
+
+	// modules disabled by default are checked too
+	enabled := true
+
+	for _, status := range modules.GetStatuses() {
+		if _, err := modules.Configure(status.Name, models.ModuleSettingsPatch{Enabled: &enabled}); err != nil {
+			t.Fatalf("module %s not enabled: %s", status.Name, err.Error())
+		}
+	}
+
+	mal := engine.NewMALSearch(engine.NewScraper())
+
+	for _, malID := range malIDs {
+		malID := malID
+
+		t.Run(fmt.Sprintf("mal-%d", malID), func(t *testing.T) {
+			path := filepath.Join(goldenDir, fmt.Sprintf("mal-%d.json", malID))
+			result, err := runGoldenCase(mal, malID)
+
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			if missing := transport.Missing(); len(missing) > 0 {
+				t.Fatalf("fixtures not found for:\n%s", strings.Join(missing, "\n"))
+			}
+
+			if *updateGolden {
+				err = os.MkdirAll(goldenDir, 0755)
+
+				if err == nil {
+					err = ioutil.WriteFile(path, result, 0644)
+				}
+
+				if err != nil {
+					t.Fatalf("golden file %s not written: %s", path, err.Error())
+				}
+
+				return
+			}
+
+			expected, err := ioutil.ReadFile(path)
+
+			if err != nil {
+				t.Fatalf("golden file %s not read: %s", path, err.Error())
+			}
+
+			if !bytes.Equal(expected, result) {
+				t.Errorf("golden mismatch\n--- expected\n%s\n--- got\n%s", expected, result)
+			}
+		})
+	}
+}
+
+func runGoldenCase(mal *engine.MALSearch, malID int) ([]byte, error) {
+	if !mal.Refresh(engine.GetMALURL(malID)) {
+		return nil, fmt.Errorf("MAL page not scraped")
+	}
+
+	anime, err := models.GetAnimeByMALID(malID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	episodes, err := models.FindEpisodes(anime.ID, 0, "", "", utils.GetPageInfo(1), "number", false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// modules run concurrently, so episodes sharing a number are sorted by origin
+	sort.SliceStable(episodes, func(i, j int) bool {
+		if episodes[i].Number != episodes[j].Number {
+			return episodes[i].Number < episodes[j].Number
+		}
+
+		return episodes[i].From < episodes[j].From
+	})
+
+	result, err := json.MarshalIndent(&goldenCase{
+		Anime:    anime,
+		Episodes: episodes,
+	}, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, '\n'), nil
+}
+
+func getGoldenIDs(dir string) ([]int, error) {
+	var ids []int
+
+	files, err := ioutil.ReadDir(dir)
+
+	if os.IsNotExist(err) {
+		return ids, nil
+	} else if err != nil {
+		return ids, err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+
+		if !strings.HasPrefix(name, "mal-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "mal-"), ".json"))
+
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
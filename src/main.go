@@ -4,6 +4,7 @@ import (
 	"aniapi-go/api"
 	"aniapi-go/database"
 	"aniapi-go/engine"
+	"aniapi-go/models"
 	"aniapi-go/utils"
 	"log"
 	"net/http"
@@ -13,6 +14,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
 
 	go func() {
 		log.Fatal(http.ListenAndServe(":6060", nil))
@@ -22,7 +27,26 @@ func main() {
 
 	server.Handle("/api/.*", api.Router)
 
-	database.Init()
+	if os.Getenv("STORAGE") == "memory" {
+		models.SetStore(models.NewMemoryStore())
+		log.Printf("STORAGE env var set to memory, data will not be persisted")
+	} else {
+		database.Init()
+
+		if os.Getenv("MIGRATE_ON_START") != "false" {
+			err := database.Migrate()
+
+			if err != nil {
+				log.Fatalf("Could not migrate MongoDB: %s\n", err.Error())
+			}
+		}
+	}
+
+	err := models.InitAnimeIDs()
+
+	if err != nil {
+		log.Fatalf("Could not initialize anime IDs: %s\n", err.Error())
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -30,13 +54,15 @@ func main() {
 		log.Printf("PORT env var not found, using 8080 as default")
 	}
 
-	go engine.StartQueue()
-
 	utils.LoadProxies()
+	utils.LoadRateLimits()
+	utils.LoadFixtures()
 	scraper := engine.NewScraper()
+	engine.StartQueue(scraper)
+	go engine.StartWebhooks()
 	go scraper.Start()
 
-	err := http.ListenAndServe(":"+port, server)
+	err = http.ListenAndServe(":"+port, server)
 
 	if err != nil {
 		log.Fatalf("Could not start server: %s\n", err.Error())
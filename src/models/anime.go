@@ -1,14 +1,12 @@
 package models
 
 import (
-	"aniapi-go/database"
 	"aniapi-go/utils"
 	"fmt"
+	"log"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // AnimeStatus is the enumerator type of anime's status
@@ -25,28 +23,96 @@ const (
 	AnimeStatusNotYet AnimeStatus = 2
 )
 
+// AnimeSeason is the enumerator type of anime's airing season
+type AnimeSeason string
+
+const (
+	// SeasonWinter refer to january to march
+	SeasonWinter AnimeSeason = "winter"
+	// SeasonSpring refer to april to june
+	SeasonSpring AnimeSeason = "spring"
+	// SeasonSummer refer to july to september
+	SeasonSummer AnimeSeason = "summer"
+	// SeasonFall refer to october to december
+	SeasonFall AnimeSeason = "fall"
+)
+
 // Anime is the MongoDB model of an anime document
 type Anime struct {
-	AiringStart       time.Time          `bson:"airing_start" json:"airing_from"`
-	AiringEnd         time.Time          `bson:"airing_end" json:"airing_to"`
-	AlternativesTitle []string           `bson:"alternatives_title" json:"other_titles"`
-	AniListID         int                `bson:"anilist_id" json:"anilist_id"`
-	CreationDate      time.Time          `bson:"creation_date" json:"-"`
-	Genres            []string           `bson:"genres" json:"genres"`
-	ID                int                `bson:"id" json:"id"`
-	MainTitle         string             `bson:"main_title" json:"title"`
-	MongoID           primitive.ObjectID `bson:"_id" json:"-"`
-	MyAnimeListID     int                `bson:"mal_id" json:"mal_id"`
-	Picture           string             `bson:"picture" json:"picture"`
-	Score             float32            `bson:"score" json:"score"`
-	Status            AnimeStatus        `bson:"status" json:"status"`
-	Type              string             `bson:"type" json:"type"`
-	UpdateDate        time.Time          `bson:"update_date" json:"-"`
+	AiringStart          time.Time           `bson:"airing_start" json:"airing_from"`
+	AiringStartPrecision utils.DatePrecision `bson:"airing_start_precision" json:"airing_from_precision"`
+	AiringEnd            time.Time           `bson:"airing_end" json:"airing_to"`
+	AiringEndPrecision   utils.DatePrecision `bson:"airing_end_precision" json:"airing_to_precision"`
+	AlternativesTitle    []string            `bson:"alternatives_title" json:"other_titles"`
+	AniListID            int                 `bson:"anilist_id" json:"anilist_id"`
+	BannerImage          string              `bson:"banner_image" json:"banner_image"`
+	Broadcast            string              `bson:"broadcast" json:"broadcast"`
+	CoverImage           string              `bson:"cover_image" json:"cover_image"`
+	CreationDate         time.Time           `bson:"creation_date" json:"-"`
+	Description          string              `bson:"description" json:"description"`
+	Duration             int                 `bson:"duration" json:"duration"`
+	Episodes             int                 `bson:"episodes" json:"episodes"`
+	Genres               []string            `bson:"genres" json:"genres"`
+	ID                   int                 `bson:"id" json:"id"`
+	MainTitle            string              `bson:"main_title" json:"title"`
+	Members              int                 `bson:"members" json:"members"`
+	MongoID              primitive.ObjectID  `bson:"_id" json:"-"`
+	MyAnimeListID        int                 `bson:"mal_id" json:"mal_id"`
+	NextAiringEpisode    *AiringEpisode      `bson:"next_airing_episode" json:"next_airing_episode"`
+	NextRefresh          time.Time           `bson:"next_refresh" json:"-"`
+	Picture              string              `bson:"picture" json:"picture"`
+	Popularity           int                 `bson:"popularity" json:"popularity"`
+	Producers            []string            `bson:"producers" json:"producers"`
+	Rank                 int                 `bson:"rank" json:"rank"`
+	Rating               string              `bson:"rating" json:"rating"`
+	Relations            []AnimeRelation     `bson:"relations" json:"relations"`
+	Score                float32             `bson:"score" json:"score"`
+	Season               AnimeSeason         `bson:"season" json:"season"`
+	SeasonYear           int                 `bson:"season_year" json:"season_year"`
+	Source               string              `bson:"source" json:"source"`
+	Status               AnimeStatus         `bson:"status" json:"status"`
+	Studios              []string            `bson:"studios" json:"studios"`
+	Tags                 []string            `bson:"tags" json:"tags"`
+	Titles               AnimeTitles         `bson:"titles" json:"titles"`
+	Type                 string              `bson:"type" json:"type"`
+	UpdateDate           time.Time           `bson:"update_date" json:"-"`
+}
+
+// AnimeTitles are the localized titles of an anime
+type AnimeTitles struct {
+	English string `bson:"english" json:"english"`
+	Native  string `bson:"native" json:"native"`
+	Romaji  string `bson:"romaji" json:"romaji"`
+}
+
+// AnimeRelation is a link to a related anime, like a sequel or a prequel
+type AnimeRelation struct {
+	MyAnimeListID int    `bson:"mal_id" json:"mal_id"`
+	Relation      string `bson:"relation" json:"relation"`
+	Title         string `bson:"title" json:"title"`
+}
+
+// AiringEpisode is the next episode expected to air for an anime
+type AiringEpisode struct {
+	AiringAt time.Time `bson:"airing_at" json:"airing_at"`
+	Episode  int       `bson:"episode" json:"episode"`
+}
+
+// AnimeCollision is a value shared by more than one anime on a unique field
+type AnimeCollision struct {
+	Field string `json:"field"`
+	Value int    `json:"value"`
+	IDs   []int  `json:"ids"`
 }
 
 // AnimeCollectionName is a string value of animes MongoDB collection name
 var AnimeCollectionName string = "animes"
 
+// CounterCollectionName is a string value of counters MongoDB collection name
+var CounterCollectionName string = "counters"
+
+var animeUniqueFields = []string{"id", "mal_id", "anilist_id"}
+
 // SetStatus converts MAL status to model one
 func (a *Anime) SetStatus(s string) {
 	if s == "Finished Airing" {
@@ -63,6 +129,8 @@ func (a *Anime) SetStatus(s string) {
 // IsValid checks if an anime model has the following props:
 // - no Hentai genre
 // - no duplicate
+// An anime already stored with the same MAL id is updated with the new
+// values, main title included
 func (a *Anime) IsValid() bool {
 	valid := true
 
@@ -76,19 +144,17 @@ func (a *Anime) IsValid() bool {
 		valid = false
 	}
 
-	filter := bson.M{
-		"main_title": a.MainTitle,
+	if a.MyAnimeListID == 0 {
+		return valid
 	}
 
-	ref := &Anime{}
-	ctx := database.GetContext(10)
-	err := database.GetCollection(AnimeCollectionName).FindOne(ctx, filter).Decode(&ref)
+	ref, err := store.Animes.GetByMALID(a.MyAnimeListID)
 
-	if err == nil && ref.MyAnimeListID != a.MyAnimeListID {
-		valid = false
-	} else if err == nil && ref.MyAnimeListID == a.MyAnimeListID {
+	if err == nil {
 		ref.AiringStart = a.AiringStart
+		ref.AiringStartPrecision = a.AiringStartPrecision
 		ref.AiringEnd = a.AiringEnd
+		ref.AiringEndPrecision = a.AiringEndPrecision
 
 		for _, title := range a.AlternativesTitle {
 			if !isTitleDuplicate(ref.AlternativesTitle, title) {
@@ -97,18 +163,43 @@ func (a *Anime) IsValid() bool {
 		}
 
 		ref.AniListID = a.AniListID
+		ref.BannerImage = a.BannerImage
+		ref.Broadcast = a.Broadcast
+		ref.CoverImage = a.CoverImage
+		ref.Description = a.Description
+		ref.Duration = a.Duration
+		ref.Episodes = a.Episodes
 		ref.Genres = a.Genres
+		ref.MainTitle = a.MainTitle
+		ref.Members = a.Members
+		ref.NextAiringEpisode = a.NextAiringEpisode
 		ref.Picture = a.Picture
+		ref.Popularity = a.Popularity
+		ref.Producers = a.Producers
+		ref.Rank = a.Rank
+		ref.Rating = a.Rating
+		ref.Relations = a.Relations
 		ref.Score = a.Score
+		ref.Season = a.Season
+		ref.SeasonYear = a.SeasonYear
+		ref.Source = a.Source
+		ref.Studios = a.Studios
+		ref.Tags = a.Tags
+		ref.Titles = a.Titles
 
 		if ref.Status != a.Status {
 			sBefore := convertAnimeStatusToString(ref.Status)
 			sAfter := convertAnimeStatusToString(a.Status)
 
+			oldStatus := ref.Status
+			newStatus := a.Status
+
 			n := &Notification{
 				AnimeID:   ref.ID,
 				AnilistID: ref.AniListID,
 				Message:   fmt.Sprintf("Status changed from <b>%s</b> to <b>%s</b>", sBefore, sAfter),
+				NewStatus: &newStatus,
+				OldStatus: &oldStatus,
 				Type:      TypeAnimeChange,
 			}
 			n.Save()
@@ -136,173 +227,122 @@ func isTitleDuplicate(l []string, t string) bool {
 
 // GetAnime returns an existing anime model
 func GetAnime(id int) (*Anime, error) {
-	anime := &Anime{}
-
-	filter := bson.M{
-		"id": id,
-	}
-
-	ctx := database.GetContext(10)
-	err := database.GetCollection(AnimeCollectionName).FindOne(ctx, filter).Decode(anime)
-
-	if err != nil {
-		return anime, err
-	}
+	return store.Animes.Get(id)
+}
 
-	return anime, nil
+// GetAnimeByMALID returns an existing anime model by its MyAnimeList id
+func GetAnimeByMALID(malID int) (*Anime, error) {
+	return store.Animes.GetByMALID(malID)
 }
 
 // FindAnimes returns a paginated list of filtered animes
 func FindAnimes(title string, genres []string, showType string, page *utils.PageInfo, sort string, desc bool) ([]Anime, error) {
-	animes := make([]Anime, page.Size)
-
-	filter := bson.M{
-		"$or": bson.A{
-			bson.M{
-				"main_title": bson.M{
-					"$regex": primitive.Regex{
-						Pattern: ".*" + title + ".*", Options: "i",
-					},
-				},
-			},
-			bson.M{
-				"alternatives_title": bson.M{
-					"$in": []primitive.Regex{
-						primitive.Regex{
-							Pattern: ".*" + title + ".*", Options: "i",
-						},
-					},
-				},
-			},
-		},
-	}
+	return store.Animes.Find(title, genres, showType, page, sort, desc)
+}
 
-	if len(genres) > 0 {
-		f := []primitive.Regex{}
+// FindAnimesByIDs returns the animes having the given ids
+func FindAnimesByIDs(ids []int) ([]Anime, error) {
+	return store.Animes.FindByIDs(ids)
+}
 
-		for _, g := range genres {
-			f = append(f, primitive.Regex{
-				Pattern: g,
-				Options: "i",
-			})
-		}
+// FindAnimesByStatus returns every anime having one of the given statuses
+func FindAnimesByStatus(statuses ...AnimeStatus) ([]Anime, error) {
+	return store.Animes.FindByStatus(statuses)
+}
 
-		filter["genres"] = bson.M{
-			"$in": f,
-		}
-	}
+// Save create or update an anime model on the store
+// A new anime gets its id only when inserted, it is left unset on failure
+func (a *Anime) Save() error {
+	if a.MongoID == primitive.NilObjectID {
+		id, err := store.Counters.Next(AnimeCollectionName)
 
-	if showType != "" {
-		filter["type"] = bson.M{
-			"$regex": primitive.Regex{
-				Pattern: ".*" + showType + ".*", Options: "i",
-			},
+		if err != nil {
+			log.Printf("ANIME %s (%d) NOT INSERTED, NO ID AVAILABLE: %s", a.MainTitle, a.MyAnimeListID, err.Error())
+			return err
 		}
-	}
-
-	pagination := database.PaginateQuery(page)
 
-	if sort != "" {
-		direction := 1
+		a.MongoID = primitive.NewObjectID()
+		a.CreationDate = time.Now()
+		a.ID = id
+		a.NextRefresh = a.NextRefreshDate()
 
-		if desc {
-			direction = -1
-		}
+		err = store.Animes.Insert(a)
 
-		pagination.SetSort(bson.M{
-			sort: direction,
-		})
-	}
+		if err != nil {
+			log.Printf("ANIME %s (%d) NOT INSERTED: %s", a.MainTitle, a.MyAnimeListID, err.Error())
 
-	ctx := database.GetContext(10)
-	cur, err := database.GetCollection(AnimeCollectionName).Find(ctx, filter, pagination)
+			a.ID = 0
+			a.MongoID = primitive.NilObjectID
+			a.CreationDate = time.Time{}
+		}
 
-	if err != nil {
-		return animes, err
+		return err
 	}
 
-	defer cur.Close(ctx)
+	a.UpdateDate = time.Now()
+	a.NextRefresh = a.NextRefreshDate()
 
-	i := 0
-	for cur.Next(ctx) {
-		err = cur.Decode(&animes[i])
+	err := store.Animes.Update(a)
 
-		if err != nil {
-			return animes, err
-		}
-
-		i++
+	if err != nil {
+		log.Printf("ANIME %s (%d) NOT UPDATED: %s", a.MainTitle, a.ID, err.Error())
 	}
 
-	return animes[0:i], nil
+	return err
 }
 
-// Save create or update an anime model on MongoDB
-func (a *Anime) Save() {
-	if a.MongoID == primitive.NilObjectID {
-		a.MongoID = primitive.NewObjectID()
-		a.CreationDate = time.Now()
-		a.ID = getNextAvailableID()
+// InitAnimeIDs prepares anime ID allocation
+// Should be called once at startup, before any anime is saved:
+// - the animes sequence is moved after the highest existing id
+// - existing collisions on id, mal_id and anilist_id are reported
+// - unique indexes are created when no collision is found
+func InitAnimeIDs() error {
+	max, err := store.Animes.MaxID()
 
-		if a.ID == -1 {
-			return
-		}
-
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(AnimeCollectionName).InsertOne(ctx, a)
-	} else {
-		a.UpdateDate = time.Now()
+	if err != nil {
+		return err
+	}
 
-		filter := bson.M{
-			"main_title": a.MainTitle,
-		}
+	err = store.Counters.Sync(AnimeCollectionName, max)
 
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(AnimeCollectionName).UpdateOne(ctx, filter, bson.M{"$set": a})
+	if err != nil {
+		return err
 	}
-}
 
-func getNextAvailableID() int {
-	ctx := database.GetContext(10)
-	count, err := database.GetCollection(AnimeCollectionName).CountDocuments(ctx, bson.M{})
+	collisions, err := FindAnimeCollisions()
 
 	if err != nil {
-		return -1
-	} else if count == 0 {
-		return 1
+		return err
 	}
 
-	limit := int64(1)
-	options := &options.FindOptions{
-		Limit: &limit,
-		Sort: bson.M{
-			"id": -1,
-		},
+	for _, c := range collisions {
+		log.Printf("ANIME COLLISION ON %s %d BETWEEN IDS %v", c.Field, c.Value, c.IDs)
 	}
 
-	ctx = database.GetContext(10)
-	cur, err := database.GetCollection(AnimeCollectionName).Find(ctx, bson.M{}, options)
-
-	if err != nil {
-		return -1
+	if len(collisions) > 0 {
+		log.Printf("ANIME UNIQUE INDEXES NOT CREATED, %d COLLISIONS FOUND", len(collisions))
+		return nil
 	}
 
-	defer cur.Close(ctx)
+	return store.Animes.EnsureIndexes()
+}
 
-	exist := cur.TryNext(ctx)
+// FindAnimeCollisions returns every value shared by more than one anime
+// on the id, mal_id and anilist_id fields
+func FindAnimeCollisions() ([]AnimeCollision, error) {
+	var collisions []AnimeCollision
 
-	if exist {
-		temp := &Anime{}
-		err = cur.Decode(temp)
+	for _, field := range animeUniqueFields {
+		c, err := store.Animes.Collisions(field)
 
 		if err != nil {
-			return -1
+			return collisions, err
 		}
 
-		return temp.ID + 1
+		collisions = append(collisions, c...)
 	}
 
-	return -1
+	return collisions, nil
 }
 
 func convertAnimeStatusToString(status AnimeStatus) string {
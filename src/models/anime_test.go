@@ -0,0 +1,103 @@
+package models
+
+import (
+	"aniapi-go/database"
+	"os"
+	"testing"
+)
+
+// testStores returns the stores to run the repository tests against
+// MongoDB is used only when MONGODB_TEST_URL is set, its MONGODB_TEST_DB
+// database (default aniapi_test) is emptied by the tests
+func testStores(t *testing.T) map[string]func() *Store {
+	stores := map[string]func() *Store{
+		"memory": NewMemoryStore,
+	}
+
+	url := os.Getenv("MONGODB_TEST_URL")
+
+	if url == "" {
+		return stores
+	}
+
+	db := os.Getenv("MONGODB_TEST_DB")
+
+	if db == "" {
+		db = "aniapi_test"
+	}
+
+	os.Setenv("MONGODB_URL", url)
+	os.Setenv("MONGODB_DB", db)
+	database.Init()
+
+	stores["mongo"] = func() *Store {
+		ctx, cancel := database.GetContext(10)
+		defer cancel()
+
+		if err := database.Conn.Database(db).Drop(ctx); err != nil {
+			t.Fatalf("test database not dropped: %s", err.Error())
+		}
+
+		s := NewMongoStore()
+
+		if err := s.Animes.EnsureIndexes(); err != nil {
+			t.Fatalf("indexes not created: %s", err.Error())
+		}
+
+		return s
+	}
+
+	return stores
+}
+
+func TestAnimeRename(t *testing.T) {
+	for name, newStore := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			SetStore(newStore())
+
+			anime := &Anime{
+				MainTitle:     "Shingeki no Kyojin",
+				MyAnimeListID: 16498,
+				Type:          "TV",
+			}
+
+			if err := anime.Save(); err != nil {
+				t.Fatalf("anime not inserted: %s", err.Error())
+			}
+
+			renamed := &Anime{
+				MainTitle:     "Attack on Titan",
+				MyAnimeListID: 16498,
+				Type:          "TV",
+			}
+
+			if !renamed.IsValid() {
+				t.Fatalf("renamed anime not valid")
+			}
+
+			if err := renamed.Save(); err != nil {
+				t.Fatalf("renamed anime not saved: %s", err.Error())
+			}
+
+			if renamed.ID != anime.ID {
+				t.Errorf("expected id %d, got %d", anime.ID, renamed.ID)
+			}
+
+			stored, err := GetAnimeByMALID(16498)
+
+			if err != nil {
+				t.Fatalf("anime not found: %s", err.Error())
+			}
+
+			if stored.MainTitle != "Attack on Titan" || stored.ID != anime.ID {
+				t.Errorf("expected anime %d Attack on Titan, got %d %s", anime.ID, stored.ID, stored.MainTitle)
+			}
+
+			animes, err := FindAnimesByIDs([]int{anime.ID, anime.ID + 1})
+
+			if err != nil || len(animes) != 1 {
+				t.Errorf("expected 1 anime, got %d (%v)", len(animes), err)
+			}
+		})
+	}
+}
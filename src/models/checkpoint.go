@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Checkpoint is the MongoDB model of a crawl progress document
+type Checkpoint struct {
+	Name       string    `bson:"_id" json:"name"`
+	Letter     string    `bson:"letter" json:"letter"`
+	Page       int       `bson:"page" json:"page"`
+	LastMalID  int       `bson:"last_mal_id" json:"last_mal_id"`
+	UpdateDate time.Time `bson:"update_date" json:"update_date"`
+}
+
+// CheckpointCollectionName is a string value of checkpoints MongoDB collection name
+var CheckpointCollectionName string = "checkpoints"
+
+// GetCheckpoint returns an existing checkpoint model
+func GetCheckpoint(name string) (*Checkpoint, error) {
+	return store.Checkpoints.Get(name)
+}
+
+// Save create or update a checkpoint model on the store
+func (c *Checkpoint) Save() error {
+	c.UpdateDate = time.Now()
+
+	return store.Checkpoints.Upsert(c)
+}
+
+// ResetCheckpoint removes a checkpoint model from the store
+func ResetCheckpoint(name string) error {
+	return store.Checkpoints.Delete(name)
+}
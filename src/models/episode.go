@@ -1,12 +1,10 @@
 package models
 
 import (
-	"aniapi-go/database"
 	"aniapi-go/utils"
 	"fmt"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -39,33 +37,12 @@ var EpisodeCollectionName string = "episodes"
 // IsValid checks if an episode model has the following props:
 // - no duplicate
 func (e *Episode) IsValid() bool {
-	filter := bson.M{
-		"anime_id": e.AnimeID,
-		"from":     e.From,
-		"region":   e.Region,
-		"number":   e.Number,
-	}
-
-	ref := &Episode{}
-	ctx := database.GetContext(10)
-	err := database.GetCollection(EpisodeCollectionName).FindOne(ctx, filter).Decode(&ref)
+	ref, err := store.Episodes.GetByKey(e.AnimeID, e.From, e.Region, e.Number)
 
 	if err == nil {
 		ref.Source = e.Source
 		ref.Title = e.Title
 		*e = *ref
-	} else {
-		a, err := GetAnime(e.AnimeID)
-
-		if err == nil {
-			n := &Notification{
-				AnimeID:   a.ID,
-				AnilistID: a.AniListID,
-				Message:   fmt.Sprintf("Episode <b>%d</b> released on <b>%s</b>", e.Number, e.From),
-				Type:      TypeEpisodeChange,
-			}
-			n.Save()
-		}
 	}
 
 	return true
@@ -73,115 +50,64 @@ func (e *Episode) IsValid() bool {
 
 // GetEpisode returns an existing episode model
 func GetEpisode(animeID int, number int, region string) (*Episode, error) {
-	episode := &Episode{}
-
-	filter := bson.M{
-		"anime_id": animeID,
-		"number":   number,
-	}
-
-	if region != "" {
-		filter["region"] = region
-	}
-
-	ctx := database.GetContext(10)
-	err := database.GetCollection(EpisodeCollectionName).FindOne(ctx, filter).Decode(episode)
-
-	if err != nil {
-		return episode, err
-	}
-
-	return episode, nil
+	return store.Episodes.Get(animeID, number, region)
 }
 
 // FindEpisodes returns a paginated list of filtered episodes
 func FindEpisodes(animeID int, number int, from string, region string, page *utils.PageInfo, sort string, desc bool) ([]Episode, error) {
-	episodes := make([]Episode, page.Size)
-
-	filter := bson.M{
-		"anime_id": animeID,
-	}
-
-	if number != 0 {
-		filter["number"] = number
-	}
+	return store.Episodes.Find(animeID, number, from, region, page, sort, desc)
+}
 
-	if from != "" {
-		filter["from"] = bson.M{
-			"$regex": primitive.Regex{
-				Pattern: ".*" + from + ".*", Options: "i",
-			},
-		}
-	}
+// FindLatestEpisodeNumbers returns the highest known episode number of
+// every given anime having at least one episode
+func FindLatestEpisodeNumbers(animeIDs []int) (map[int]int, error) {
+	return store.Episodes.LatestNumbers(animeIDs)
+}
 
-	if region != "" {
-		filter["region"] = bson.M{
-			"$regex": primitive.Regex{
-				Pattern: ".*" + region + ".*", Options: "i",
-			},
-		}
+// Save create or update an episode model on the store
+// It returns true when the episode has been created
+func (e *Episode) Save() (bool, error) {
+	if !e.IsValid() {
+		return false, nil
 	}
 
-	pagination := database.PaginateQuery(page)
+	if e.MongoID == primitive.NilObjectID {
+		e.MongoID = primitive.NewObjectID()
+		e.CreationDate = time.Now()
 
-	if sort != "" {
-		direction := 1
+		if err := store.Episodes.Insert(e); err != nil {
+			e.MongoID = primitive.NilObjectID
+			e.CreationDate = time.Time{}
 
-		if desc {
-			direction = -1
+			return false, err
 		}
 
-		pagination.SetSort(bson.M{
-			sort: direction,
-		})
-	}
-
-	ctx := database.GetContext(10)
-	cur, err := database.GetCollection(EpisodeCollectionName).Find(ctx, filter, pagination)
+		e.notifyRelease()
 
-	if err != nil {
-		return episodes, err
+		return true, nil
 	}
 
-	defer cur.Close(ctx)
-
-	i := 0
-	for cur.Next(ctx) {
-		err = cur.Decode(&episodes[i])
-
-		if err != nil {
-			return episodes, err
-		}
-
-		i++
-	}
+	e.UpdateDate = time.Now()
 
-	return episodes[0:i], nil
+	return false, store.Episodes.Update(e)
 }
 
-// Save create or update an episode model on MongoDB
-func (e *Episode) Save() {
-	if !e.IsValid() {
+// notifyRelease creates the notification of a new episode of its anime
+func (e *Episode) notifyRelease() {
+	a, err := GetAnime(e.AnimeID)
+
+	if err != nil {
 		return
 	}
 
-	if e.MongoID == primitive.NilObjectID {
-		e.MongoID = primitive.NewObjectID()
-		e.CreationDate = time.Now()
-
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(EpisodeCollectionName).InsertOne(ctx, e)
-	} else {
-		e.UpdateDate = time.Now()
-
-		filter := bson.M{
-			"anime_id": e.AnimeID,
-			"from":     e.From,
-			"region":   e.Region,
-			"number":   e.Number,
-		}
-
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(EpisodeCollectionName).UpdateOne(ctx, filter, bson.M{"$set": e})
+	n := &Notification{
+		AnimeID:       a.ID,
+		AnilistID:     a.AniListID,
+		EpisodeNumber: e.Number,
+		From:          e.From,
+		Message:       fmt.Sprintf("Episode <b>%d</b> released on <b>%s</b>", e.Number, e.From),
+		Region:        e.Region,
+		Type:          TypeEpisodeChange,
 	}
+	n.Save()
 }
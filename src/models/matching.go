@@ -1,12 +1,9 @@
 package models
 
 import (
-	"aniapi-go/database"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Matching is the MongoDB model of a matching document
@@ -29,26 +26,19 @@ var MatchingCollectionName string = "matchings"
 // IsValid checks if a matching model has the following props:
 // - no duplicate
 func (m *Matching) IsValid() bool {
-	filter := bson.M{
-		"anime_id": m.AnimeID,
-		"from":     m.From,
-		"title":    m.Title,
-	}
-
-	ref := &Matching{}
-	ctx := database.GetContext(10)
-	err := database.GetCollection(MatchingCollectionName).FindOne(ctx, filter).Decode(&ref)
+	ref, err := store.Matchings.GetByKey(m.AnimeID, m.From, m.Title)
 
 	if err == nil {
 		ref.URL = m.URL
 		ref.Episodes = m.Episodes
+		ref.Ratio = m.Ratio
 		*m = *ref
 	}
 
 	return true
 }
 
-// Save create or update a matching model on MongoDB
+// Save create or update a matching model on the store
 func (m *Matching) Save() {
 	if !m.IsValid() {
 		return
@@ -59,97 +49,27 @@ func (m *Matching) Save() {
 		m.CreationDate = time.Now()
 		m.Votes = 0
 
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(MatchingCollectionName).InsertOne(ctx, m)
+		_ = store.Matchings.Insert(m)
 	} else {
 		m.UpdateDate = time.Now()
 
-		filter := bson.M{
-			"anime_id": m.AnimeID,
-			"from":     m.From,
-			"title":    m.Title,
-		}
-
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(MatchingCollectionName).UpdateOne(ctx, filter, bson.M{"$set": m})
+		_ = store.Matchings.Update(m)
 	}
 }
 
 // IncreaseVotes increases a matching existing model votes count
 func (m *Matching) IncreaseVotes() error {
-	filter := bson.M{
-		"anime_id": m.AnimeID,
-		"from":     m.From,
-		"title":    m.Title,
-	}
-
-	ctx := database.GetContext(10)
-	_, err := database.GetCollection(MatchingCollectionName).UpdateOne(ctx, filter, bson.M{
-		"$inc": bson.M{
-			"votes": 1,
-		},
-	})
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return store.Matchings.IncreaseVotes(m.AnimeID, m.From, m.Title)
 }
 
 // FindMatchings returns a paginated list of filtered matchings
 func FindMatchings(animeID int, from string, sort string, desc bool) ([]Matching, error) {
-	var matchings []Matching
-
-	filter := bson.M{
-		"anime_id": animeID,
-	}
-
-	if from != "" {
-		filter["from"] = bson.M{
-			"$regex": primitive.Regex{
-				Pattern: ".*" + from + ".*", Options: "i",
-			},
-		}
-	}
-
-	pagination := &options.FindOptions{}
-
-	if sort != "" {
-		direction := 1
-
-		if desc {
-			direction = -1
-		}
-
-		pagination.SetSort(bson.M{
-			sort: direction,
-		})
-	}
-
-	ctx := database.GetContext(10)
-	cur, err := database.GetCollection(MatchingCollectionName).Find(ctx, filter, pagination)
+	matchings, err := store.Matchings.Find(animeID, from, sort, desc)
 
 	if err != nil {
 		return matchings, err
 	}
 
-	defer cur.Close(ctx)
-
-	i := 0
-	for cur.Next(ctx) {
-		m := &Matching{}
-		err = cur.Decode(m)
-
-		if err != nil {
-			return matchings, err
-		}
-
-		matchings = append(matchings, *m)
-
-		i++
-	}
-
 	if len(matchings) == 0 {
 		matchings = make([]Matching, 0)
 	}
@@ -0,0 +1,1018 @@
+package models
+
+import (
+	"aniapi-go/utils"
+	"bytes"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryCounterRepository struct {
+	mutex    sync.Mutex
+	counters map[string]int
+}
+
+type memoryAnimeRepository struct {
+	mutex  sync.RWMutex
+	animes []Anime
+}
+
+type memoryEpisodeRepository struct {
+	mutex    sync.RWMutex
+	episodes []Episode
+}
+
+type memoryMatchingRepository struct {
+	mutex     sync.RWMutex
+	matchings []Matching
+}
+
+type memoryNotificationRepository struct {
+	mutex         sync.RWMutex
+	notifications []Notification
+}
+
+type memoryCheckpointRepository struct {
+	mutex       sync.RWMutex
+	checkpoints map[string]Checkpoint
+}
+
+type memoryQueueRepository struct {
+	mutex sync.RWMutex
+	items []QueueItem
+}
+
+type memoryWebhookRepository struct {
+	mutex    sync.RWMutex
+	webhooks []Webhook
+}
+
+type memoryWebhookDeliveryRepository struct {
+	mutex      sync.RWMutex
+	deliveries []WebhookDelivery
+}
+
+type memoryModuleSettingsRepository struct {
+	mutex    sync.RWMutex
+	settings map[string]ModuleSettingsPatch
+}
+
+// NewMemoryStore returns a store which keeps every model in memory
+// Filtering, sorting and pagination behave like the MongoDB store
+func NewMemoryStore() *Store {
+	return &Store{
+		Counters:          &memoryCounterRepository{counters: make(map[string]int)},
+		Animes:            &memoryAnimeRepository{},
+		Episodes:          &memoryEpisodeRepository{},
+		Matchings:         &memoryMatchingRepository{},
+		Notifications:     &memoryNotificationRepository{},
+		Checkpoints:       &memoryCheckpointRepository{checkpoints: make(map[string]Checkpoint)},
+		Queue:             &memoryQueueRepository{},
+		Webhooks:          &memoryWebhookRepository{},
+		WebhookDeliveries: &memoryWebhookDeliveryRepository{},
+		ModuleSettings:    &memoryModuleSettingsRepository{settings: make(map[string]ModuleSettingsPatch)},
+	}
+}
+
+// containsRegex mimics a MongoDB case insensitive ".*value.*" regex filter
+func containsRegex(value string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i).*" + value + ".*")
+}
+
+// sortByField sorts a slice of models by the field having the given bson name
+// Unknown fields keep the insertion order, as MongoDB does
+func sortByField(slice interface{}, field string, desc bool) {
+	if field == "" {
+		return
+	}
+
+	v := reflect.ValueOf(slice)
+	index := bsonFieldIndex(v.Type().Elem(), field)
+
+	if index == -1 {
+		return
+	}
+
+	sort.SliceStable(slice, func(i, j int) bool {
+		a := v.Index(i).Field(index)
+		b := v.Index(j).Field(index)
+
+		if desc {
+			return lessValue(b, a)
+		}
+
+		return lessValue(a, b)
+	})
+}
+
+func bsonFieldIndex(t reflect.Type, field string) int {
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("bson"), ",")[0]
+
+		if tag == field {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func lessValue(a reflect.Value, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+
+	if t, ok := a.Interface().(time.Time); ok {
+		return t.Before(b.Interface().(time.Time))
+	}
+
+	return false
+}
+
+// paginate returns the slice bounds of a page, clamped to the slice length
+func paginate(length int, page *utils.PageInfo) (int, int) {
+	start := page.Start
+	end := page.Start + page.Size
+
+	if start > length {
+		start = length
+	}
+
+	if end > length {
+		end = length
+	}
+
+	return start, end
+}
+
+func containsInt(l []int, v int) bool {
+	for _, i := range l {
+		if i == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *memoryCounterRepository) Next(name string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.counters[name]++
+	return r.counters[name], nil
+}
+
+func (r *memoryCounterRepository) Sync(name string, value int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if value > r.counters[name] {
+		r.counters[name] = value
+	}
+
+	return nil
+}
+
+func (r *memoryAnimeRepository) Get(id int) (*Anime, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, a := range r.animes {
+		if a.ID == id {
+			anime := a
+			return &anime, nil
+		}
+	}
+
+	return &Anime{}, ErrNotFound
+}
+
+func (r *memoryAnimeRepository) GetByMALID(malID int) (*Anime, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, a := range r.animes {
+		if a.MyAnimeListID == malID {
+			anime := a
+			return &anime, nil
+		}
+	}
+
+	return &Anime{}, ErrNotFound
+}
+
+func (r *memoryAnimeRepository) Find(title string, genres []string, showType string, page *utils.PageInfo, sort string, desc bool) ([]Anime, error) {
+	animes := make([]Anime, 0)
+
+	titleRegex, err := containsRegex(title)
+
+	if err != nil {
+		return animes, err
+	}
+
+	var genresRegex []*regexp.Regexp
+
+	for _, g := range genres {
+		re, err := regexp.Compile("(?i)" + g)
+
+		if err != nil {
+			return animes, err
+		}
+
+		genresRegex = append(genresRegex, re)
+	}
+
+	typeRegex, err := containsRegex(showType)
+
+	if err != nil {
+		return animes, err
+	}
+
+	r.mutex.RLock()
+
+	for _, a := range r.animes {
+		match := titleRegex.MatchString(a.MainTitle)
+
+		for _, t := range a.AlternativesTitle {
+			match = match || titleRegex.MatchString(t)
+		}
+
+		if !match {
+			continue
+		}
+
+		if len(genresRegex) > 0 {
+			match = false
+
+			for _, re := range genresRegex {
+				for _, g := range a.Genres {
+					match = match || re.MatchString(g)
+				}
+			}
+
+			if !match {
+				continue
+			}
+		}
+
+		if showType != "" && !typeRegex.MatchString(a.Type) {
+			continue
+		}
+
+		animes = append(animes, a)
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(animes, sort, desc)
+
+	start, end := paginate(len(animes), page)
+	return animes[start:end], nil
+}
+
+func (r *memoryAnimeRepository) FindByIDs(ids []int) ([]Anime, error) {
+	return r.filter(func(a *Anime) bool {
+		return containsInt(ids, a.ID)
+	}), nil
+}
+
+func (r *memoryAnimeRepository) FindByStatus(statuses []AnimeStatus) ([]Anime, error) {
+	return r.filter(func(a *Anime) bool {
+		for _, s := range statuses {
+			if a.Status == s {
+				return true
+			}
+		}
+
+		return false
+	}), nil
+}
+
+func (r *memoryAnimeRepository) FindBySeason(season AnimeSeason, year int, start time.Time, end time.Time) ([]Anime, error) {
+	return r.filter(func(a *Anime) bool {
+		if a.Season == season && a.SeasonYear == year {
+			return true
+		}
+
+		return (a.Season == "" || a.Status == AnimeStatusAiring) && a.AirsBetween(start, end)
+	}), nil
+}
+
+// filter returns the animes matching a predicate, sorted by id
+func (r *memoryAnimeRepository) filter(match func(a *Anime) bool) []Anime {
+	animes := make([]Anime, 0)
+
+	r.mutex.RLock()
+
+	for i := range r.animes {
+		if match(&r.animes[i]) {
+			animes = append(animes, r.animes[i])
+		}
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(animes, "id", false)
+
+	return animes
+}
+
+// conflicts checks if an anime shares a unique field with another stored anime
+func (r *memoryAnimeRepository) conflicts(a *Anime) bool {
+	for _, ref := range r.animes {
+		if ref.MongoID == a.MongoID {
+			continue
+		}
+
+		if ref.ID == a.ID ||
+			(a.MyAnimeListID > 0 && ref.MyAnimeListID == a.MyAnimeListID) ||
+			(a.AniListID > 0 && ref.AniListID == a.AniListID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *memoryAnimeRepository) Insert(a *Anime) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.conflicts(a) {
+		return ErrDuplicate
+	}
+
+	r.animes = append(r.animes, *a)
+	return nil
+}
+
+func (r *memoryAnimeRepository) Update(a *Anime) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.animes {
+		if r.animes[i].ID == a.ID {
+			if r.conflicts(a) {
+				return ErrDuplicate
+			}
+
+			r.animes[i] = *a
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryAnimeRepository) SetNextRefresh(id int, next time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.animes {
+		if r.animes[i].ID == id {
+			r.animes[i].NextRefresh = next
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryAnimeRepository) FindDue(now time.Time, limit int) ([]Anime, error) {
+	animes := make([]Anime, 0)
+
+	r.mutex.RLock()
+
+	for _, a := range r.animes {
+		if !a.NextRefresh.After(now) {
+			animes = append(animes, a)
+		}
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(animes, "next_refresh", false)
+
+	if len(animes) > limit {
+		animes = animes[:limit]
+	}
+
+	return animes, nil
+}
+
+func (r *memoryAnimeRepository) MaxID() (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	max := 0
+
+	for _, a := range r.animes {
+		if a.ID > max {
+			max = a.ID
+		}
+	}
+
+	return max, nil
+}
+
+func (r *memoryAnimeRepository) Collisions(field string) ([]AnimeCollision, error) {
+	var collisions []AnimeCollision
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	index := bsonFieldIndex(reflect.TypeOf(Anime{}), field)
+
+	if index == -1 {
+		return collisions, nil
+	}
+
+	groups := make(map[int][]int)
+	var values []int
+
+	for _, a := range r.animes {
+		value := int(reflect.ValueOf(a).Field(index).Int())
+
+		if value <= 0 {
+			continue
+		}
+
+		if _, ok := groups[value]; !ok {
+			values = append(values, value)
+		}
+
+		groups[value] = append(groups[value], a.ID)
+	}
+
+	for _, value := range values {
+		if len(groups[value]) > 1 {
+			collisions = append(collisions, AnimeCollision{
+				Field: field,
+				Value: value,
+				IDs:   groups[value],
+			})
+		}
+	}
+
+	return collisions, nil
+}
+
+func (r *memoryAnimeRepository) EnsureIndexes() error {
+	return nil
+}
+
+func (r *memoryEpisodeRepository) Get(animeID int, number int, region string) (*Episode, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, e := range r.episodes {
+		if e.AnimeID == animeID && e.Number == number && (region == "" || string(e.Region) == region) {
+			episode := e
+			return &episode, nil
+		}
+	}
+
+	return &Episode{}, ErrNotFound
+}
+
+func (r *memoryEpisodeRepository) GetByKey(animeID int, from string, region EpisodeRegion, number int) (*Episode, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, e := range r.episodes {
+		if e.AnimeID == animeID && e.From == from && e.Region == region && e.Number == number {
+			episode := e
+			return &episode, nil
+		}
+	}
+
+	return &Episode{}, ErrNotFound
+}
+
+func (r *memoryEpisodeRepository) Find(animeID int, number int, from string, region string, page *utils.PageInfo, sort string, desc bool) ([]Episode, error) {
+	episodes := make([]Episode, 0)
+
+	fromRegex, err := containsRegex(from)
+
+	if err != nil {
+		return episodes, err
+	}
+
+	regionRegex, err := containsRegex(region)
+
+	if err != nil {
+		return episodes, err
+	}
+
+	r.mutex.RLock()
+
+	for _, e := range r.episodes {
+		if e.AnimeID != animeID {
+			continue
+		}
+
+		if number != 0 && e.Number != number {
+			continue
+		}
+
+		if !fromRegex.MatchString(e.From) || !regionRegex.MatchString(string(e.Region)) {
+			continue
+		}
+
+		episodes = append(episodes, e)
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(episodes, sort, desc)
+
+	start, end := paginate(len(episodes), page)
+	return episodes[start:end], nil
+}
+
+func (r *memoryEpisodeRepository) LatestNumbers(animeIDs []int) (map[int]int, error) {
+	numbers := make(map[int]int)
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, e := range r.episodes {
+		if containsInt(animeIDs, e.AnimeID) && e.Number > numbers[e.AnimeID] {
+			numbers[e.AnimeID] = e.Number
+		}
+	}
+
+	return numbers, nil
+}
+
+func (r *memoryEpisodeRepository) Insert(e *Episode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.episodes = append(r.episodes, *e)
+	return nil
+}
+
+func (r *memoryEpisodeRepository) Update(e *Episode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, ref := range r.episodes {
+		if ref.AnimeID == e.AnimeID && ref.From == e.From && ref.Region == e.Region && ref.Number == e.Number {
+			r.episodes[i] = *e
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryMatchingRepository) GetByKey(animeID int, from string, title string) (*Matching, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, m := range r.matchings {
+		if m.AnimeID == animeID && m.From == from && m.Title == title {
+			matching := m
+			return &matching, nil
+		}
+	}
+
+	return &Matching{}, ErrNotFound
+}
+
+func (r *memoryMatchingRepository) Find(animeID int, from string, sort string, desc bool) ([]Matching, error) {
+	var matchings []Matching
+
+	fromRegex, err := containsRegex(from)
+
+	if err != nil {
+		return matchings, err
+	}
+
+	r.mutex.RLock()
+
+	for _, m := range r.matchings {
+		if m.AnimeID == animeID && fromRegex.MatchString(m.From) {
+			matchings = append(matchings, m)
+		}
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(matchings, sort, desc)
+
+	return matchings, nil
+}
+
+func (r *memoryMatchingRepository) Insert(m *Matching) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.matchings = append(r.matchings, *m)
+	return nil
+}
+
+func (r *memoryMatchingRepository) Update(m *Matching) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, ref := range r.matchings {
+		if ref.AnimeID == m.AnimeID && ref.From == m.From && ref.Title == m.Title {
+			r.matchings[i] = *m
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryMatchingRepository) IncreaseVotes(animeID int, from string, title string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, ref := range r.matchings {
+		if ref.AnimeID == animeID && ref.From == from && ref.Title == title {
+			r.matchings[i].Votes++
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryNotificationRepository) Find(f NotificationFilter) ([]Notification, error) {
+	notifications := make([]Notification, 0)
+
+	filtered := len(f.AnimeIDs) > 0 || len(f.AnilistIDs) > 0
+
+	r.mutex.RLock()
+
+	for _, n := range r.notifications {
+		if n.CreationDate.Before(f.Since) {
+			continue
+		}
+
+		if filtered && !containsInt(f.AnimeIDs, n.AnimeID) && !containsInt(f.AnilistIDs, n.AnilistID) {
+			continue
+		}
+
+		if (f.Type != "" && n.Type != f.Type) || (f.From != "" && n.From != f.From) || (f.Region != "" && n.Region != f.Region) {
+			continue
+		}
+
+		if !f.Before.IsZero() && bytes.Compare(n.MongoID[:], f.Before[:]) >= 0 {
+			continue
+		}
+
+		if !f.After.IsZero() && bytes.Compare(n.MongoID[:], f.After[:]) <= 0 {
+			continue
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	r.mutex.RUnlock()
+
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return bytes.Compare(notifications[i].MongoID[:], notifications[j].MongoID[:]) > 0
+	})
+
+	if f.Limit > 0 && len(notifications) > f.Limit {
+		notifications = notifications[:f.Limit]
+	}
+
+	return notifications, nil
+}
+
+func (r *memoryNotificationRepository) Insert(n *Notification) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.notifications = append(r.notifications, *n)
+	return nil
+}
+
+func (r *memoryCheckpointRepository) Get(name string) (*Checkpoint, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if c, ok := r.checkpoints[name]; ok {
+		return &c, nil
+	}
+
+	return &Checkpoint{}, ErrNotFound
+}
+
+func (r *memoryCheckpointRepository) Upsert(c *Checkpoint) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoints[c.Name] = *c
+	return nil
+}
+
+func (r *memoryCheckpointRepository) Delete(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.checkpoints, name)
+	return nil
+}
+
+func (r *memoryQueueRepository) Get(id int) (*QueueItem, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, q := range r.items {
+		if q.ID == id {
+			item := q
+			return &item, nil
+		}
+	}
+
+	return &QueueItem{}, ErrNotFound
+}
+
+func (r *memoryQueueRepository) GetActive(animeID int) (*QueueItem, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, q := range r.items {
+		if q.Active && q.AnimeID == animeID {
+			item := q
+			return &item, nil
+		}
+	}
+
+	return &QueueItem{}, ErrNotFound
+}
+
+func (r *memoryQueueRepository) Find(status QueueStatus, page *utils.PageInfo) ([]QueueItem, error) {
+	items := make([]QueueItem, 0)
+
+	r.mutex.RLock()
+
+	for _, q := range r.items {
+		if status == "" || q.Status == status {
+			items = append(items, q)
+		}
+	}
+
+	r.mutex.RUnlock()
+
+	sortQueueItems(items, status)
+
+	start, end := paginate(len(items), page)
+	return items[start:end], nil
+}
+
+// sortQueueItems mimics the MongoDB queue sort, see queueSort
+func sortQueueItems(items []QueueItem, status QueueStatus) {
+	if status == QueueStatusPending || status == QueueStatusRunning {
+		sortByField(items, "creation_date", false)
+		sortByField(items, "priority", true)
+	} else {
+		sortByField(items, "update_date", true)
+	}
+}
+
+func (r *memoryQueueRepository) Insert(q *QueueItem) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, ref := range r.items {
+		if ref.ID == q.ID || (q.Active && ref.Active && ref.AnimeID == q.AnimeID) {
+			return ErrDuplicate
+		}
+	}
+
+	r.items = append(r.items, *q)
+	return nil
+}
+
+func (r *memoryQueueRepository) Update(q *QueueItem) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.items {
+		if r.items[i].MongoID == q.MongoID {
+			r.items[i] = *q
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryQueueRepository) UpdatePending(q *QueueItem) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.items {
+		if r.items[i].MongoID == q.MongoID && r.items[i].Status == QueueStatusPending {
+			r.items[i] = *q
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryQueueRepository) Claim(now time.Time) (*QueueItem, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	next := -1
+
+	for i, q := range r.items {
+		if q.Status != QueueStatusPending || q.RunAfter.After(now) {
+			continue
+		}
+
+		if next == -1 || q.Priority > r.items[next].Priority ||
+			(q.Priority == r.items[next].Priority && q.CreationDate.Before(r.items[next].CreationDate)) {
+			next = i
+		}
+	}
+
+	if next == -1 {
+		return nil, ErrNotFound
+	}
+
+	r.items[next].Status = QueueStatusRunning
+	r.items[next].UpdateDate = now
+	r.items[next].Attempts++
+
+	item := r.items[next]
+	return &item, nil
+}
+
+func (r *memoryQueueRepository) Release() (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	released := 0
+
+	for i := range r.items {
+		if r.items[i].Status == QueueStatusRunning {
+			r.items[i].Status = QueueStatusPending
+			released++
+		}
+	}
+
+	return released, nil
+}
+
+func (r *memoryWebhookRepository) Get(id int) (*Webhook, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, w := range r.webhooks {
+		if w.ID == id {
+			webhook := w
+			return &webhook, nil
+		}
+	}
+
+	return &Webhook{}, ErrNotFound
+}
+
+func (r *memoryWebhookRepository) Find() ([]Webhook, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	webhooks := make([]Webhook, len(r.webhooks))
+	copy(webhooks, r.webhooks)
+
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) Insert(w *Webhook) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.webhooks = append(r.webhooks, *w)
+	return nil
+}
+
+func (r *memoryWebhookRepository) Delete(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, w := range r.webhooks {
+		if w.ID == id {
+			r.webhooks = append(r.webhooks[:i], r.webhooks[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrNotFound
+}
+
+func (r *memoryWebhookDeliveryRepository) Find(webhookID int, status DeliveryStatus, page *utils.PageInfo) ([]WebhookDelivery, error) {
+	deliveries := make([]WebhookDelivery, 0)
+
+	r.mutex.RLock()
+
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(deliveries, "id", true)
+
+	start, end := paginate(len(deliveries), page)
+	return deliveries[start:end], nil
+}
+
+func (r *memoryWebhookDeliveryRepository) FindDue(now time.Time, limit int) ([]WebhookDelivery, error) {
+	deliveries := make([]WebhookDelivery, 0)
+
+	r.mutex.RLock()
+
+	for _, d := range r.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttempt.After(now) {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(deliveries, "next_attempt", false)
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) Insert(d *WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.deliveries = append(r.deliveries, *d)
+	return nil
+}
+
+func (r *memoryWebhookDeliveryRepository) Update(d *WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.deliveries {
+		if r.deliveries[i].MongoID == d.MongoID {
+			r.deliveries[i] = *d
+			break
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryModuleSettingsRepository) Find() ([]ModuleSettingsPatch, error) {
+	settings := make([]ModuleSettingsPatch, 0)
+
+	r.mutex.RLock()
+
+	for _, s := range r.settings {
+		settings = append(settings, s)
+	}
+
+	r.mutex.RUnlock()
+
+	sortByField(settings, "_id", false)
+
+	return settings, nil
+}
+
+func (r *memoryModuleSettingsRepository) Upsert(s *ModuleSettingsPatch) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.settings[s.Name] = *s
+	return nil
+}
+
+func (r *memoryModuleSettingsRepository) Delete(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.settings, name)
+	return nil
+}
@@ -0,0 +1,262 @@
+package models
+
+import (
+	"aniapi-go/database"
+	"aniapi-go/utils"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	database.RegisterMigration(database.Migration{
+		Version:     1,
+		Description: "create episodes, matchings and notifications lookup indexes",
+		Up:          createLookupIndexes,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     2,
+		Description: "create animes lookup indexes",
+		Up:          createAnimeIndexes,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     3,
+		Description: "backfill notifications update_date from creation_date",
+		Up:          backfillNotificationsUpdateDate,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     4,
+		Description: "create animes next_refresh index",
+		Up:          createAnimeRefreshIndex,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     5,
+		Description: "create queue indexes",
+		Up:          createQueueIndexes,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     6,
+		Description: "create webhooks and webhook deliveries indexes",
+		Up:          createWebhookIndexes,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     7,
+		Description: "create notifications history indexes",
+		Up:          createNotificationHistoryIndexes,
+	})
+
+	database.RegisterMigration(database.Migration{
+		Version:     8,
+		Description: "backfill animes airing dates precision",
+		Up:          backfillAiringPrecision,
+	})
+}
+
+// indexKeys returns an index keys document, fields prefixed by "-" are descending
+func indexKeys(fields ...string) bson.D {
+	keys := bson.D{}
+
+	for _, f := range fields {
+		if strings.HasPrefix(f, "-") {
+			keys = append(keys, bson.E{Key: f[1:], Value: -1})
+		} else {
+			keys = append(keys, bson.E{Key: f, Value: 1})
+		}
+	}
+
+	return keys
+}
+
+func createIndexes(db *mongo.Database, collection string, indexes ...mongo.IndexModel) error {
+	ctx, cancel := database.GetContext(60)
+	defer cancel()
+	_, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+func createLookupIndexes(db *mongo.Database) error {
+	err := createIndexes(db, EpisodeCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("anime_id", "number", "region"),
+		Options: options.Index().SetName("anime_id_number_region"),
+	}, mongo.IndexModel{
+		Keys:    indexKeys("anime_id", "from", "region", "number"),
+		Options: options.Index().SetName("anime_id_from_region_number"),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	err = createIndexes(db, MatchingCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("anime_id", "from"),
+		Options: options.Index().SetName("anime_id_from"),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return createIndexes(db, NotificationCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("-update_date"),
+		Options: options.Index().SetName("update_date"),
+	}, mongo.IndexModel{
+		Keys:    indexKeys("anime_id", "anilist_id", "type"),
+		Options: options.Index().SetName("anime_id_anilist_id_type"),
+	})
+}
+
+func createAnimeIndexes(db *mongo.Database) error {
+	return createIndexes(db, AnimeCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("main_title"),
+		Options: options.Index().SetName("main_title"),
+	})
+}
+
+func createAnimeRefreshIndex(db *mongo.Database) error {
+	return createIndexes(db, AnimeCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("next_refresh"),
+		Options: options.Index().SetName("next_refresh"),
+	})
+}
+
+// createQueueIndexes creates the claim order index and the unique index
+// which keeps one active queue item for each anime
+func createQueueIndexes(db *mongo.Database) error {
+	return createIndexes(db, QueueCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("id"),
+		Options: options.Index().SetName("id").SetUnique(true),
+	}, mongo.IndexModel{
+		Keys:    indexKeys("status", "-priority", "creation_date"),
+		Options: options.Index().SetName("status_priority_creation_date"),
+	}, mongo.IndexModel{
+		Keys: indexKeys("anime_id"),
+		Options: options.Index().
+			SetName("anime_id_active").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"active": true}),
+	})
+}
+
+func createWebhookIndexes(db *mongo.Database) error {
+	err := createIndexes(db, WebhookCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("id"),
+		Options: options.Index().SetName("id").SetUnique(true),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return createIndexes(db, WebhookDeliveryCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("webhook_id", "-id"),
+		Options: options.Index().SetName("webhook_id_id"),
+	}, mongo.IndexModel{
+		Keys:    indexKeys("status", "next_attempt"),
+		Options: options.Index().SetName("status_next_attempt"),
+	})
+}
+
+func createNotificationHistoryIndexes(db *mongo.Database) error {
+	return createIndexes(db, NotificationCollectionName, mongo.IndexModel{
+		Keys:    indexKeys("anime_id", "-_id"),
+		Options: options.Index().SetName("anime_id_id"),
+	}, mongo.IndexModel{
+		Keys:    indexKeys("anilist_id", "-_id"),
+		Options: options.Index().SetName("anilist_id_id"),
+	}, mongo.IndexModel{
+		Keys:    indexKeys("-creation_date"),
+		Options: options.Index().SetName("creation_date"),
+	})
+}
+
+func backfillNotificationsUpdateDate(db *mongo.Database) error {
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"update_date": bson.M{"$exists": false}},
+			bson.M{"update_date": bson.M{"$lte": time.Time{}}},
+		},
+	}
+
+	ctx, cancel := database.GetContext(60)
+	defer cancel()
+	cur, err := db.Collection(NotificationCollectionName).Find(ctx, filter)
+
+	if err != nil {
+		return err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		n := &Notification{}
+		err = cur.Decode(n)
+
+		if err != nil {
+			return err
+		}
+
+		updateCtx, updateCancel := database.GetContext(10)
+		_, err = db.Collection(NotificationCollectionName).UpdateOne(updateCtx, bson.M{
+			"_id": n.MongoID,
+		}, bson.M{
+			"$set": bson.M{
+				"update_date": n.CreationDate,
+			},
+		})
+		updateCancel()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// backfillAiringPrecision sets the precision of airing dates stored before
+// precisions existed: day for known dates, unknown otherwise
+func backfillAiringPrecision(db *mongo.Database) error {
+	fields := map[string]string{
+		"airing_start": "airing_start_precision",
+		"airing_end":   "airing_end_precision",
+	}
+
+	for date, precision := range fields {
+		missing := bson.M{precision: bson.M{"$in": bson.A{nil, ""}}}
+
+		known := bson.M{
+			"$and": bson.A{
+				missing,
+				bson.M{date: bson.M{"$gt": time.Time{}}},
+			},
+		}
+
+		ctx, cancel := database.GetContext(60)
+		_, err := db.Collection(AnimeCollectionName).UpdateMany(ctx, known, bson.M{
+			"$set": bson.M{precision: utils.DatePrecisionDay},
+		})
+
+		if err == nil {
+			_, err = db.Collection(AnimeCollectionName).UpdateMany(ctx, missing, bson.M{
+				"$set": bson.M{precision: utils.DatePrecisionUnknown},
+			})
+		}
+
+		cancel()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"net/url"
+	"time"
+)
+
+// ModuleSettings are the settings a module runs with: its defaults, changed
+// by the modules config file and by the stored settings
+type ModuleSettings struct {
+	BaseURL     string        `json:"base_url"`
+	Concurrency int           `json:"concurrency"`
+	Enabled     bool          `json:"enabled"`
+	Name        string        `json:"name"`
+	Proxy       bool          `json:"proxy"`
+	Region      EpisodeRegion `json:"region"`
+}
+
+// ModuleSettingsPatch is a partial change of module settings, as read from
+// the modules config file or from an admin request
+// It is also the MongoDB model of the settings changed at runtime, where only
+// the changed fields are stored
+type ModuleSettingsPatch struct {
+	BaseURL     *string        `bson:"base_url,omitempty" json:"base_url" yaml:"base_url"`
+	Concurrency *int           `bson:"concurrency,omitempty" json:"concurrency" yaml:"concurrency"`
+	Enabled     *bool          `bson:"enabled,omitempty" json:"enabled" yaml:"enabled"`
+	Name        string         `bson:"_id" json:"-" yaml:"-"`
+	Proxy       *bool          `bson:"proxy,omitempty" json:"proxy" yaml:"proxy"`
+	Region      *EpisodeRegion `bson:"region,omitempty" json:"region" yaml:"region"`
+	UpdateDate  time.Time      `bson:"update_date" json:"-" yaml:"-"`
+}
+
+// ModuleSettingsCollectionName is a string value of module settings MongoDB collection name
+var ModuleSettingsCollectionName string = "module_settings"
+
+// ModuleMaxConcurrency is the highest number of animes a module can work on
+// at the same time
+var ModuleMaxConcurrency = 16
+
+// IsValid checks if a module settings model has the following props:
+// - an absolute http or https base url
+// - a known region
+// - a concurrency between one and ModuleMaxConcurrency
+func (s *ModuleSettings) IsValid() bool {
+	u, err := url.Parse(s.BaseURL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+
+	if s.Region != RegionIT && s.Region != RegionEN {
+		return false
+	}
+
+	return s.Concurrency > 0 && s.Concurrency <= ModuleMaxConcurrency
+}
+
+// FindModuleSettings returns every stored module settings change
+func FindModuleSettings() ([]ModuleSettingsPatch, error) {
+	return store.ModuleSettings.Find()
+}
+
+// ResetModuleSettings removes a module settings change from the store
+func ResetModuleSettings(name string) error {
+	return store.ModuleSettings.Delete(name)
+}
+
+// Apply changes module settings with the fields set in the patch
+func (p *ModuleSettingsPatch) Apply(s *ModuleSettings) {
+	if p.BaseURL != nil {
+		s.BaseURL = *p.BaseURL
+	}
+
+	if p.Concurrency != nil {
+		s.Concurrency = *p.Concurrency
+	}
+
+	if p.Enabled != nil {
+		s.Enabled = *p.Enabled
+	}
+
+	if p.Proxy != nil {
+		s.Proxy = *p.Proxy
+	}
+
+	if p.Region != nil {
+		s.Region = *p.Region
+	}
+}
+
+// Merge returns the patch changed with the fields set in another patch
+func (p ModuleSettingsPatch) Merge(o ModuleSettingsPatch) ModuleSettingsPatch {
+	if o.BaseURL != nil {
+		p.BaseURL = o.BaseURL
+	}
+
+	if o.Concurrency != nil {
+		p.Concurrency = o.Concurrency
+	}
+
+	if o.Enabled != nil {
+		p.Enabled = o.Enabled
+	}
+
+	if o.Proxy != nil {
+		p.Proxy = o.Proxy
+	}
+
+	if o.Region != nil {
+		p.Region = o.Region
+	}
+
+	return p
+}
+
+// Save create or update a module settings change on the store
+func (p *ModuleSettingsPatch) Save() error {
+	p.UpdateDate = time.Now()
+
+	return store.ModuleSettings.Upsert(p)
+}
@@ -0,0 +1,1198 @@
+package models
+
+import (
+	"aniapi-go/database"
+	"aniapi-go/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoCounterRepository struct{}
+type mongoAnimeRepository struct{}
+type mongoEpisodeRepository struct{}
+type mongoMatchingRepository struct{}
+type mongoNotificationRepository struct{}
+type mongoCheckpointRepository struct{}
+
+type mongoQueueRepository struct{}
+
+type mongoWebhookRepository struct{}
+
+type mongoWebhookDeliveryRepository struct{}
+
+type mongoModuleSettingsRepository struct{}
+
+// NewMongoStore returns a store backed by the MongoDB collections
+func NewMongoStore() *Store {
+	return &Store{
+		Counters:          &mongoCounterRepository{},
+		Animes:            &mongoAnimeRepository{},
+		Episodes:          &mongoEpisodeRepository{},
+		Matchings:         &mongoMatchingRepository{},
+		Notifications:     &mongoNotificationRepository{},
+		Checkpoints:       &mongoCheckpointRepository{},
+		Queue:             &mongoQueueRepository{},
+		Webhooks:          &mongoWebhookRepository{},
+		WebhookDeliveries: &mongoWebhookDeliveryRepository{},
+		ModuleSettings:    &mongoModuleSettingsRepository{},
+	}
+}
+
+func findOne(collection string, filter bson.M, result interface{}) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	err := database.GetCollection(collection).FindOne(ctx, filter).Decode(result)
+
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// writeError converts MongoDB duplicate key errors to ErrDuplicate
+func writeError(err error) error {
+	if e, ok := err.(mongo.WriteException); ok {
+		for _, we := range e.WriteErrors {
+			if we.Code == 11000 {
+				return ErrDuplicate
+			}
+		}
+	}
+
+	return err
+}
+
+func sortQuery(pagination *options.FindOptions, sort string, desc bool) {
+	if sort != "" {
+		direction := 1
+
+		if desc {
+			direction = -1
+		}
+
+		pagination.SetSort(bson.M{
+			sort: direction,
+		})
+	}
+}
+
+func (r *mongoCounterRepository) Next(name string) (int, error) {
+	counter := &struct {
+		Seq int `bson:"seq"`
+	}{}
+
+	filter := bson.M{
+		"_id": name,
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	err := database.GetCollection(CounterCollectionName).FindOneAndUpdate(ctx, filter, bson.M{
+		"$inc": bson.M{
+			"seq": 1,
+		},
+	}, opts).Decode(counter)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return counter.Seq, nil
+}
+
+func (r *mongoCounterRepository) Sync(name string, value int) error {
+	filter := bson.M{
+		"_id": name,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(CounterCollectionName).UpdateOne(ctx, filter, bson.M{
+		"$max": bson.M{
+			"seq": value,
+		},
+	}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (r *mongoAnimeRepository) Get(id int) (*Anime, error) {
+	anime := &Anime{}
+
+	filter := bson.M{
+		"id": id,
+	}
+
+	err := findOne(AnimeCollectionName, filter, anime)
+	return anime, err
+}
+
+func (r *mongoAnimeRepository) GetByMALID(malID int) (*Anime, error) {
+	anime := &Anime{}
+
+	filter := bson.M{
+		"mal_id": malID,
+	}
+
+	err := findOne(AnimeCollectionName, filter, anime)
+	return anime, err
+}
+
+func (r *mongoAnimeRepository) Find(title string, genres []string, showType string, page *utils.PageInfo, sort string, desc bool) ([]Anime, error) {
+	animes := make([]Anime, page.Size)
+
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{
+				"main_title": bson.M{
+					"$regex": primitive.Regex{
+						Pattern: ".*" + title + ".*", Options: "i",
+					},
+				},
+			},
+			bson.M{
+				"alternatives_title": bson.M{
+					"$in": []primitive.Regex{
+						primitive.Regex{
+							Pattern: ".*" + title + ".*", Options: "i",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if len(genres) > 0 {
+		f := []primitive.Regex{}
+
+		for _, g := range genres {
+			f = append(f, primitive.Regex{
+				Pattern: g,
+				Options: "i",
+			})
+		}
+
+		filter["genres"] = bson.M{
+			"$in": f,
+		}
+	}
+
+	if showType != "" {
+		filter["type"] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + showType + ".*", Options: "i",
+			},
+		}
+	}
+
+	pagination := database.PaginateQuery(page)
+	sortQuery(pagination, sort, desc)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(AnimeCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return animes, err
+	}
+
+	defer cur.Close(ctx)
+
+	i := 0
+	for cur.Next(ctx) {
+		err = cur.Decode(&animes[i])
+
+		if err != nil {
+			return animes, err
+		}
+
+		i++
+	}
+
+	return animes[0:i], nil
+}
+
+func (r *mongoAnimeRepository) FindByIDs(ids []int) ([]Anime, error) {
+	filter := bson.M{
+		"id": bson.M{"$in": nonNilInts(ids)},
+	}
+
+	return r.find(filter, options.Find().SetSort(bson.M{"id": 1}))
+}
+
+func (r *mongoAnimeRepository) FindByStatus(statuses []AnimeStatus) ([]Anime, error) {
+	filter := bson.M{
+		"status": bson.M{"$in": statuses},
+	}
+
+	return r.find(filter, options.Find().SetSort(bson.M{"id": 1}))
+}
+
+func (r *mongoAnimeRepository) FindBySeason(season AnimeSeason, year int, start time.Time, end time.Time) ([]Anime, error) {
+	precise := bson.A{utils.DatePrecisionMonth, utils.DatePrecisionDay}
+
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{
+				"season":      season,
+				"season_year": year,
+			},
+			bson.M{
+				"airing_start":           bson.M{"$lt": end},
+				"airing_start_precision": bson.M{"$in": precise},
+				"$and": bson.A{
+					bson.M{
+						"$or": bson.A{
+							bson.M{"season": bson.M{"$in": bson.A{"", nil}}},
+							bson.M{"status": AnimeStatusAiring},
+						},
+					},
+					bson.M{
+						"$or": bson.A{
+							bson.M{
+								"airing_end":           bson.M{"$gte": start},
+								"airing_end_precision": bson.M{"$in": precise},
+							},
+							bson.M{
+								"airing_end_precision": bson.M{"$nin": precise},
+								"status":               AnimeStatusAiring,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return r.find(filter, options.Find().SetSort(bson.M{"id": 1}))
+}
+
+func (r *mongoAnimeRepository) find(filter bson.M, opts *options.FindOptions) ([]Anime, error) {
+	animes := make([]Anime, 0)
+
+	ctx, cancel := database.GetContext(30)
+	defer cancel()
+	cur, err := database.GetCollection(AnimeCollectionName).Find(ctx, filter, opts)
+
+	if err != nil {
+		return animes, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		a := Anime{}
+		err = cur.Decode(&a)
+
+		if err != nil {
+			return animes, err
+		}
+
+		animes = append(animes, a)
+	}
+
+	return animes, nil
+}
+
+func (r *mongoAnimeRepository) Insert(a *Anime) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(AnimeCollectionName).InsertOne(ctx, a)
+	return writeError(err)
+}
+
+func (r *mongoAnimeRepository) Update(a *Anime) error {
+	filter := bson.M{
+		"id": a.ID,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	res, err := database.GetCollection(AnimeCollectionName).UpdateOne(ctx, filter, bson.M{"$set": a})
+
+	if err != nil {
+		return writeError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoAnimeRepository) SetNextRefresh(id int, next time.Time) error {
+	filter := bson.M{
+		"id": id,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	res, err := database.GetCollection(AnimeCollectionName).UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{
+			"next_refresh": next,
+		},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoAnimeRepository) FindDue(now time.Time, limit int) ([]Anime, error) {
+	var animes []Anime
+
+	filter := bson.M{
+		"$or": bson.A{
+			bson.M{"next_refresh": bson.M{"$exists": false}},
+			bson.M{"next_refresh": bson.M{"$lte": now}},
+		},
+	}
+
+	pagination := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.M{"next_refresh": 1})
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(AnimeCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return animes, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		a := Anime{}
+		err = cur.Decode(&a)
+
+		if err != nil {
+			return animes, err
+		}
+
+		animes = append(animes, a)
+	}
+
+	return animes, nil
+}
+
+func (r *mongoAnimeRepository) MaxID() (int, error) {
+	limit := int64(1)
+	options := &options.FindOptions{
+		Limit: &limit,
+		Sort: bson.M{
+			"id": -1,
+		},
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(AnimeCollectionName).Find(ctx, bson.M{}, options)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer cur.Close(ctx)
+
+	if cur.TryNext(ctx) {
+		temp := &Anime{}
+		err = cur.Decode(temp)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return temp.ID, nil
+	}
+
+	return 0, nil
+}
+
+func (r *mongoAnimeRepository) Collisions(field string) ([]AnimeCollision, error) {
+	var collisions []AnimeCollision
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{field: bson.M{"$gt": 0}}},
+		bson.M{"$group": bson.M{
+			"_id":   "$" + field,
+			"ids":   bson.M{"$push": "$id"},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}
+
+	ctx, cancel := database.GetContext(60)
+	defer cancel()
+	cur, err := database.GetCollection(AnimeCollectionName).Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return collisions, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		group := &struct {
+			Value int   `bson:"_id"`
+			IDs   []int `bson:"ids"`
+		}{}
+		err = cur.Decode(group)
+
+		if err != nil {
+			return collisions, err
+		}
+
+		collisions = append(collisions, AnimeCollision{
+			Field: field,
+			Value: group.Value,
+			IDs:   group.IDs,
+		})
+	}
+
+	return collisions, nil
+}
+
+func (r *mongoAnimeRepository) EnsureIndexes() error {
+	var indexes []mongo.IndexModel
+
+	for _, field := range animeUniqueFields {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys: bson.M{field: 1},
+			Options: options.Index().
+				SetName(field + "_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{field: bson.M{"$gt": 0}}),
+		})
+	}
+
+	ctx, cancel := database.GetContext(60)
+	defer cancel()
+	_, err := database.GetCollection(AnimeCollectionName).Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+func (r *mongoEpisodeRepository) Get(animeID int, number int, region string) (*Episode, error) {
+	episode := &Episode{}
+
+	filter := bson.M{
+		"anime_id": animeID,
+		"number":   number,
+	}
+
+	if region != "" {
+		filter["region"] = region
+	}
+
+	err := findOne(EpisodeCollectionName, filter, episode)
+	return episode, err
+}
+
+func (r *mongoEpisodeRepository) GetByKey(animeID int, from string, region EpisodeRegion, number int) (*Episode, error) {
+	episode := &Episode{}
+
+	filter := bson.M{
+		"anime_id": animeID,
+		"from":     from,
+		"region":   region,
+		"number":   number,
+	}
+
+	err := findOne(EpisodeCollectionName, filter, episode)
+	return episode, err
+}
+
+func (r *mongoEpisodeRepository) Find(animeID int, number int, from string, region string, page *utils.PageInfo, sort string, desc bool) ([]Episode, error) {
+	episodes := make([]Episode, page.Size)
+
+	filter := bson.M{
+		"anime_id": animeID,
+	}
+
+	if number != 0 {
+		filter["number"] = number
+	}
+
+	if from != "" {
+		filter["from"] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + from + ".*", Options: "i",
+			},
+		}
+	}
+
+	if region != "" {
+		filter["region"] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + region + ".*", Options: "i",
+			},
+		}
+	}
+
+	pagination := database.PaginateQuery(page)
+	sortQuery(pagination, sort, desc)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(EpisodeCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return episodes, err
+	}
+
+	defer cur.Close(ctx)
+
+	i := 0
+	for cur.Next(ctx) {
+		err = cur.Decode(&episodes[i])
+
+		if err != nil {
+			return episodes, err
+		}
+
+		i++
+	}
+
+	return episodes[0:i], nil
+}
+
+func (r *mongoEpisodeRepository) LatestNumbers(animeIDs []int) (map[int]int, error) {
+	numbers := make(map[int]int)
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"anime_id": bson.M{"$in": nonNilInts(animeIDs)}}},
+		bson.M{"$group": bson.M{
+			"_id":    "$anime_id",
+			"number": bson.M{"$max": "$number"},
+		}},
+	}
+
+	ctx, cancel := database.GetContext(30)
+	defer cancel()
+	cur, err := database.GetCollection(EpisodeCollectionName).Aggregate(ctx, pipeline)
+
+	if err != nil {
+		return numbers, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		group := &struct {
+			AnimeID int `bson:"_id"`
+			Number  int `bson:"number"`
+		}{}
+		err = cur.Decode(group)
+
+		if err != nil {
+			return numbers, err
+		}
+
+		numbers[group.AnimeID] = group.Number
+	}
+
+	return numbers, nil
+}
+
+func (r *mongoEpisodeRepository) Insert(e *Episode) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(EpisodeCollectionName).InsertOne(ctx, e)
+	return err
+}
+
+func (r *mongoEpisodeRepository) Update(e *Episode) error {
+	filter := bson.M{
+		"anime_id": e.AnimeID,
+		"from":     e.From,
+		"region":   e.Region,
+		"number":   e.Number,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(EpisodeCollectionName).UpdateOne(ctx, filter, bson.M{"$set": e})
+	return err
+}
+
+func (r *mongoMatchingRepository) GetByKey(animeID int, from string, title string) (*Matching, error) {
+	matching := &Matching{}
+
+	filter := bson.M{
+		"anime_id": animeID,
+		"from":     from,
+		"title":    title,
+	}
+
+	err := findOne(MatchingCollectionName, filter, matching)
+	return matching, err
+}
+
+func (r *mongoMatchingRepository) Find(animeID int, from string, sort string, desc bool) ([]Matching, error) {
+	var matchings []Matching
+
+	filter := bson.M{
+		"anime_id": animeID,
+	}
+
+	if from != "" {
+		filter["from"] = bson.M{
+			"$regex": primitive.Regex{
+				Pattern: ".*" + from + ".*", Options: "i",
+			},
+		}
+	}
+
+	pagination := &options.FindOptions{}
+	sortQuery(pagination, sort, desc)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(MatchingCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return matchings, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		m := &Matching{}
+		err = cur.Decode(m)
+
+		if err != nil {
+			return matchings, err
+		}
+
+		matchings = append(matchings, *m)
+	}
+
+	return matchings, nil
+}
+
+func (r *mongoMatchingRepository) Insert(m *Matching) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(MatchingCollectionName).InsertOne(ctx, m)
+	return err
+}
+
+func (r *mongoMatchingRepository) Update(m *Matching) error {
+	filter := bson.M{
+		"anime_id": m.AnimeID,
+		"from":     m.From,
+		"title":    m.Title,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(MatchingCollectionName).UpdateOne(ctx, filter, bson.M{"$set": m})
+	return err
+}
+
+func (r *mongoMatchingRepository) IncreaseVotes(animeID int, from string, title string) error {
+	filter := bson.M{
+		"anime_id": animeID,
+		"from":     from,
+		"title":    title,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(MatchingCollectionName).UpdateOne(ctx, filter, bson.M{
+		"$inc": bson.M{
+			"votes": 1,
+		},
+	})
+
+	return err
+}
+
+func (r *mongoNotificationRepository) Find(f NotificationFilter) ([]Notification, error) {
+	notifications := make([]Notification, 0)
+
+	filter := bson.M{
+		"creation_date": bson.M{
+			"$gte": f.Since,
+		},
+	}
+
+	if len(f.AnimeIDs) > 0 || len(f.AnilistIDs) > 0 {
+		filter["$or"] = bson.A{
+			bson.M{"anime_id": bson.M{"$in": nonNilInts(f.AnimeIDs)}},
+			bson.M{"anilist_id": bson.M{"$in": nonNilInts(f.AnilistIDs)}},
+		}
+	}
+
+	if f.Type != "" {
+		filter["type"] = f.Type
+	}
+
+	if f.From != "" {
+		filter["from"] = f.From
+	}
+
+	if f.Region != "" {
+		filter["region"] = f.Region
+	}
+
+	id := bson.M{}
+
+	if !f.Before.IsZero() {
+		id["$lt"] = f.Before
+	}
+
+	if !f.After.IsZero() {
+		id["$gt"] = f.After
+	}
+
+	if len(id) > 0 {
+		filter["_id"] = id
+	}
+
+	pagination := options.Find().SetSort(bson.M{"_id": -1})
+
+	if f.Limit > 0 {
+		pagination.SetLimit(int64(f.Limit))
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(NotificationCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return notifications, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		n := Notification{}
+		err = cur.Decode(&n)
+
+		if err != nil {
+			return notifications, err
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, nil
+}
+
+// nonNilInts avoids a null $in operand, which MongoDB rejects
+func nonNilInts(l []int) []int {
+	if l == nil {
+		return []int{}
+	}
+
+	return l
+}
+
+func (r *mongoNotificationRepository) Insert(n *Notification) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(NotificationCollectionName).InsertOne(ctx, n)
+	return err
+}
+
+func (r *mongoCheckpointRepository) Get(name string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+
+	filter := bson.M{
+		"_id": name,
+	}
+
+	err := findOne(CheckpointCollectionName, filter, checkpoint)
+	return checkpoint, err
+}
+
+func (r *mongoCheckpointRepository) Upsert(c *Checkpoint) error {
+	filter := bson.M{
+		"_id": c.Name,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(CheckpointCollectionName).ReplaceOne(ctx, filter, c, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoCheckpointRepository) Delete(name string) error {
+	filter := bson.M{
+		"_id": name,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(CheckpointCollectionName).DeleteOne(ctx, filter)
+	return err
+}
+
+func (r *mongoQueueRepository) Get(id int) (*QueueItem, error) {
+	q := &QueueItem{}
+
+	filter := bson.M{
+		"id": id,
+	}
+
+	err := findOne(QueueCollectionName, filter, q)
+	return q, err
+}
+
+func (r *mongoQueueRepository) GetActive(animeID int) (*QueueItem, error) {
+	q := &QueueItem{}
+
+	filter := bson.M{
+		"anime_id": animeID,
+		"active":   true,
+	}
+
+	err := findOne(QueueCollectionName, filter, q)
+	return q, err
+}
+
+func (r *mongoQueueRepository) Find(status QueueStatus, page *utils.PageInfo) ([]QueueItem, error) {
+	items := make([]QueueItem, 0)
+
+	filter := bson.M{}
+
+	if status != "" {
+		filter["status"] = status
+	}
+
+	pagination := database.PaginateQuery(page)
+	pagination.SetSort(queueSort(status))
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(QueueCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return items, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		q := QueueItem{}
+		err = cur.Decode(&q)
+
+		if err != nil {
+			return items, err
+		}
+
+		items = append(items, q)
+	}
+
+	return items, nil
+}
+
+// queueSort returns the queue items order: runnable items come in claim
+// order, processed ones from the most recent
+func queueSort(status QueueStatus) bson.D {
+	if status == QueueStatusPending || status == QueueStatusRunning {
+		return indexKeys("-priority", "creation_date")
+	}
+
+	return indexKeys("-update_date")
+}
+
+func (r *mongoQueueRepository) Insert(q *QueueItem) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(QueueCollectionName).InsertOne(ctx, q)
+	return writeError(err)
+}
+
+func (r *mongoQueueRepository) Update(q *QueueItem) error {
+	filter := bson.M{
+		"_id": q.MongoID,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(QueueCollectionName).UpdateOne(ctx, filter, bson.M{"$set": q})
+	return writeError(err)
+}
+
+func (r *mongoQueueRepository) UpdatePending(q *QueueItem) error {
+	filter := bson.M{
+		"_id":    q.MongoID,
+		"status": QueueStatusPending,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	res, err := database.GetCollection(QueueCollectionName).UpdateOne(ctx, filter, bson.M{"$set": q})
+
+	if err != nil {
+		return writeError(err)
+	}
+
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoQueueRepository) Claim(now time.Time) (*QueueItem, error) {
+	q := &QueueItem{}
+
+	filter := bson.M{
+		"status":    QueueStatusPending,
+		"run_after": bson.M{"$lte": now},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetSort(queueSort(QueueStatusPending)).
+		SetReturnDocument(options.After)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	err := database.GetCollection(QueueCollectionName).FindOneAndUpdate(ctx, filter, bson.M{
+		"$set": bson.M{
+			"status":      QueueStatusRunning,
+			"update_date": now,
+		},
+		"$inc": bson.M{
+			"attempts": 1,
+		},
+	}, opts).Decode(q)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (r *mongoQueueRepository) Release() (int, error) {
+	filter := bson.M{
+		"status": QueueStatusRunning,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	res, err := database.GetCollection(QueueCollectionName).UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{
+			"status": QueueStatusPending,
+		},
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(res.ModifiedCount), nil
+}
+
+func (r *mongoWebhookRepository) Get(id int) (*Webhook, error) {
+	w := &Webhook{}
+
+	filter := bson.M{
+		"id": id,
+	}
+
+	err := findOne(WebhookCollectionName, filter, w)
+	return w, err
+}
+
+func (r *mongoWebhookRepository) Find() ([]Webhook, error) {
+	webhooks := make([]Webhook, 0)
+
+	pagination := options.Find().SetSort(bson.M{"id": 1})
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(WebhookCollectionName).Find(ctx, bson.M{}, pagination)
+
+	if err != nil {
+		return webhooks, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		w := Webhook{}
+		err = cur.Decode(&w)
+
+		if err != nil {
+			return webhooks, err
+		}
+
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, nil
+}
+
+func (r *mongoWebhookRepository) Insert(w *Webhook) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(WebhookCollectionName).InsertOne(ctx, w)
+	return writeError(err)
+}
+
+func (r *mongoWebhookRepository) Delete(id int) error {
+	filter := bson.M{
+		"id": id,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	res, err := database.GetCollection(WebhookCollectionName).DeleteOne(ctx, filter)
+
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoWebhookDeliveryRepository) Find(webhookID int, status DeliveryStatus, page *utils.PageInfo) ([]WebhookDelivery, error) {
+	filter := bson.M{
+		"webhook_id": webhookID,
+	}
+
+	if status != "" {
+		filter["status"] = status
+	}
+
+	pagination := database.PaginateQuery(page)
+	pagination.SetSort(bson.M{"id": -1})
+
+	return r.find(filter, pagination)
+}
+
+func (r *mongoWebhookDeliveryRepository) FindDue(now time.Time, limit int) ([]WebhookDelivery, error) {
+	filter := bson.M{
+		"status":       DeliveryPending,
+		"next_attempt": bson.M{"$lte": now},
+	}
+
+	pagination := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.M{"next_attempt": 1})
+
+	return r.find(filter, pagination)
+}
+
+func (r *mongoWebhookDeliveryRepository) find(filter bson.M, pagination *options.FindOptions) ([]WebhookDelivery, error) {
+	deliveries := make([]WebhookDelivery, 0)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(WebhookDeliveryCollectionName).Find(ctx, filter, pagination)
+
+	if err != nil {
+		return deliveries, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		d := WebhookDelivery{}
+		err = cur.Decode(&d)
+
+		if err != nil {
+			return deliveries, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, nil
+}
+
+func (r *mongoWebhookDeliveryRepository) Insert(d *WebhookDelivery) error {
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(WebhookDeliveryCollectionName).InsertOne(ctx, d)
+	return writeError(err)
+}
+
+func (r *mongoWebhookDeliveryRepository) Update(d *WebhookDelivery) error {
+	filter := bson.M{
+		"_id": d.MongoID,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(WebhookDeliveryCollectionName).UpdateOne(ctx, filter, bson.M{"$set": d})
+	return err
+}
+
+func (r *mongoModuleSettingsRepository) Find() ([]ModuleSettingsPatch, error) {
+	settings := make([]ModuleSettingsPatch, 0)
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	cur, err := database.GetCollection(ModuleSettingsCollectionName).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+
+	if err != nil {
+		return settings, err
+	}
+
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		s := ModuleSettingsPatch{}
+		err = cur.Decode(&s)
+
+		if err != nil {
+			return settings, err
+		}
+
+		settings = append(settings, s)
+	}
+
+	return settings, nil
+}
+
+func (r *mongoModuleSettingsRepository) Upsert(s *ModuleSettingsPatch) error {
+	filter := bson.M{
+		"_id": s.Name,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(ModuleSettingsCollectionName).ReplaceOne(ctx, filter, s, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *mongoModuleSettingsRepository) Delete(name string) error {
+	filter := bson.M{
+		"_id": name,
+	}
+
+	ctx, cancel := database.GetContext(10)
+	defer cancel()
+	_, err := database.GetCollection(ModuleSettingsCollectionName).DeleteOne(ctx, filter)
+	return err
+}
@@ -1,12 +1,13 @@
 package models
 
 import (
-	"aniapi-go/database"
+	"aniapi-go/utils"
+	"fmt"
+	"log"
+	"regexp"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // NotificationType is the enumerator type of notification's type
@@ -20,142 +21,131 @@ const (
 )
 
 // Notification is the MongoDB model of a notification document
+// Every event is kept as its own document, structured fields are set
+// depending on the type:
+// - anime: OldStatus and NewStatus
+// - episode: EpisodeNumber, From and Region
 type Notification struct {
-	Anime        *Anime             `bson:"-" json:"anime"`
-	AnimeID      int                `bson:"anime_id" json:"anime_id"`
-	AnilistID    int                `bson:"anilist_id" json:"anilist_id"`
-	CreationDate time.Time          `bson:"creation_date" json:"-"`
-	Message      string             `bson:"message" json:"message"`
-	MongoID      primitive.ObjectID `bson:"_id" json:"-"`
-	Type         NotificationType   `bson:"type" json:"type"`
-	UpdateDate   time.Time          `bson:"update_date" json:"on"`
+	Anime         *Anime             `bson:"-" json:"anime"`
+	AnimeID       int                `bson:"anime_id" json:"anime_id"`
+	AnilistID     int                `bson:"anilist_id" json:"anilist_id"`
+	CreationDate  time.Time          `bson:"creation_date" json:"-"`
+	EpisodeNumber int                `bson:"episode_number,omitempty" json:"episode_number,omitempty"`
+	From          string             `bson:"from,omitempty" json:"from,omitempty"`
+	Message       string             `bson:"message" json:"message"`
+	MongoID       primitive.ObjectID `bson:"_id" json:"id"`
+	NewStatus     *AnimeStatus       `bson:"new_status,omitempty" json:"new_status,omitempty"`
+	OldStatus     *AnimeStatus       `bson:"old_status,omitempty" json:"old_status,omitempty"`
+	Region        EpisodeRegion      `bson:"region,omitempty" json:"region,omitempty"`
+	Type          NotificationType   `bson:"type" json:"type"`
+	UpdateDate    time.Time          `bson:"update_date" json:"on"`
+}
+
+// NotificationFilter is the lookup criteria of notifications
+// Before and After are exclusive ObjectID cursors, a zero Limit means no
+// limit, empty criteria match every notification
+type NotificationFilter struct {
+	After      primitive.ObjectID
+	AnilistIDs []int
+	AnimeIDs   []int
+	Before     primitive.ObjectID
+	From       string
+	Limit      int
+	Region     EpisodeRegion
+	Since      time.Time
+	Type       NotificationType
 }
 
 // NotificationCollectionName is a string value of notifications MongoDB collection name
 var NotificationCollectionName string = "notifications"
 
-// IsValid checks if a notification model has the following props:
-// - no duplicate
-func (n *Notification) IsValid() bool {
-	filter := bson.M{
-		"anime_id":   n.AnimeID,
-		"anilist_id": n.AnilistID,
-		"type":       n.Type,
-	}
+// NotificationWindow is how long notifications are returned for by default
+// It is read in days from the NOTIFICATION_WINDOW_DAYS env var
+var NotificationWindow = time.Duration(utils.GetEnvInt("NOTIFICATION_WINDOW_DAYS", 7)) * 24 * time.Hour
 
-	ref := &Notification{}
-	ctx := database.GetContext(10)
-	err := database.GetCollection(NotificationCollectionName).FindOne(ctx, filter).Decode(&ref)
+// NotificationMaxWindow is the longest window a client can ask for
+var NotificationMaxWindow = 90 * 24 * time.Hour
+
+var htmlTagRegex = regexp.MustCompile("<[^>]*>")
+
+var notificationListeners []func(n Notification)
+
+// AddNotificationListener registers a function called after every
+// notification insert
+// Should be called before any notification is saved
+func AddNotificationListener(f func(n Notification)) {
+	notificationListeners = append(notificationListeners, f)
+}
 
-	if err == nil {
-		ref.Message = n.Message
-		*n = *ref
+// IsValid checks if a notification model has the following props:
+// - a known type
+// - an anime
+func (n *Notification) IsValid() bool {
+	if n.Type != TypeAnimeChange && n.Type != TypeEpisodeChange {
+		return false
 	}
 
-	return true
+	return n.AnimeID != 0
 }
 
-// Save create or update a notification model on MongoDB
+// Save creates a notification model on the store
+// Listeners are notified when the write succeeds
 func (n *Notification) Save() {
 	if !n.IsValid() {
 		return
 	}
 
-	if n.MongoID == primitive.NilObjectID {
-		n.MongoID = primitive.NewObjectID()
-		n.CreationDate = time.Now()
-
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(NotificationCollectionName).InsertOne(ctx, n)
-	} else {
-		n.UpdateDate = time.Now()
-
-		filter := bson.M{
-			"anime_id":   n.AnimeID,
-			"anilist_id": n.AnilistID,
-			"type":       n.Type,
-		}
-
-		ctx := database.GetContext(10)
-		_, _ = database.GetCollection(NotificationCollectionName).UpdateOne(ctx, filter, bson.M{"$set": n})
-	}
-}
-
-// FindNotifications returns a list of recent notifications
-func FindNotifications(animeIDs []int, anilistIDs []int) ([]Notification, error) {
-	var notifications []Notification
+	n.MongoID = primitive.NewObjectID()
+	n.CreationDate = time.Now()
+	n.UpdateDate = n.CreationDate
 
-	weekago := time.Now().AddDate(0, 0, -7)
+	err := store.Notifications.Insert(n)
 
-	var filterAnimeIDs bson.M
-	var filterAnilistIDs bson.M
-
-	if len(animeIDs) > 0 {
-		filterAnimeIDs = bson.M{
-			"$in": animeIDs,
-		}
+	if err != nil {
+		log.Printf("NOTIFICATION %s (%d) NOT SAVED: %s", n.Type, n.AnimeID, err.Error())
+		return
 	}
 
-	if len(anilistIDs) > 0 {
-		filterAnilistIDs = bson.M{
-			"$in": anilistIDs,
-		}
+	for _, f := range notificationListeners {
+		f(*n)
 	}
+}
 
-	filter := bson.M{
-		"update_date": bson.M{
-			"$gte": weekago,
-		},
+// Summary returns a plain text description of the notification event
+func (n *Notification) Summary() string {
+	switch {
+	case n.Type == TypeEpisodeChange && n.EpisodeNumber > 0:
+		return fmt.Sprintf("Episode %d released on %s", n.EpisodeNumber, n.From)
+	case n.Type == TypeAnimeChange && n.OldStatus != nil && n.NewStatus != nil:
+		return fmt.Sprintf("Status changed from %s to %s", convertAnimeStatusToString(*n.OldStatus), convertAnimeStatusToString(*n.NewStatus))
 	}
 
-	if filterAnimeIDs != nil || filterAnilistIDs != nil {
-		filter["$or"] = bson.A{
-			bson.M{
-				"anime_id": filterAnimeIDs,
-			},
-			bson.M{
-				"anilist_id": filterAnilistIDs,
-			},
-		}
-	}
+	return htmlTagRegex.ReplaceAllString(n.Message, "")
+}
 
-	pagination := &options.FindOptions{
-		Sort: bson.M{
-			"update_date": -1,
-		},
+// FindNotifications returns a list of notifications, from the most recent
+// A zero Since is replaced by the default window start; notifications whose
+// anime can not be loaded are kept without it
+func FindNotifications(f NotificationFilter) ([]Notification, error) {
+	if f.Since.IsZero() {
+		f.Since = time.Now().Add(-NotificationWindow)
 	}
 
-	ctx := database.GetContext(10)
-	cur, err := database.GetCollection(NotificationCollectionName).Find(ctx, filter, pagination)
+	notifications, err := store.Notifications.Find(f)
 
 	if err != nil {
 		return notifications, err
 	}
 
-	defer cur.Close(ctx)
-
-	i := 0
-	for cur.Next(ctx) {
-		n := &Notification{}
-		err = cur.Decode(n)
-
-		if err != nil {
-			return notifications, err
-		}
-
-		n.Anime, err = GetAnime(n.AnimeID)
+	for i := range notifications {
+		anime, err := GetAnime(notifications[i].AnimeID)
 
 		if err != nil {
-			return notifications, err
+			log.Printf("NOTIFICATION %s ANIME %d NOT LOADED: %s", notifications[i].MongoID.Hex(), notifications[i].AnimeID, err.Error())
+			continue
 		}
 
-		notifications = append(notifications, *n)
-
-		i++
-	}
-
-	if len(notifications) == 0 {
-		notifications = make([]Notification, 0)
+		notifications[i].Anime = anime
 	}
 
 	return notifications, nil
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"aniapi-go/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// QueueStatus is the processing state of a queue item
+type QueueStatus string
+
+const (
+	// QueueStatusPending means the item waits for a worker
+	QueueStatusPending QueueStatus = "pending"
+	// QueueStatusRunning means a worker is processing the item
+	QueueStatusRunning QueueStatus = "running"
+	// QueueStatusCompleted means the item has been processed
+	QueueStatusCompleted QueueStatus = "completed"
+	// QueueStatusFailed means the item ran out of attempts and is dead-lettered
+	QueueStatusFailed QueueStatus = "failed"
+	// QueueStatusCancelled means the item was cancelled before or while running
+	QueueStatusCancelled QueueStatus = "cancelled"
+)
+
+// Queue priorities range from QueuePriorityNormal to QueuePriorityHigh
+const (
+	// QueuePriorityNormal is the priority of routine queue items, the lowest one
+	QueuePriorityNormal = 0
+	// QueuePriorityHigh is the priority of user-triggered queue items, the highest one
+	QueuePriorityHigh = 10
+)
+
+// QueueItem is the MongoDB model of a queue item document
+// Only one active (pending or running) item can exist for each anime
+type QueueItem struct {
+	Active       bool               `bson:"active" json:"-"`
+	Anime        *Anime             `bson:"-" json:"anime,omitempty"`
+	AnimeID      int                `bson:"anime_id" json:"anime_id"`
+	Attempts     int                `bson:"attempts" json:"attempts"`
+	CreationDate time.Time          `bson:"creation_date" json:"insertion_date"`
+	ID           int                `bson:"id" json:"id"`
+	LastError    string             `bson:"last_error" json:"last_error"`
+	MaxAttempts  int                `bson:"max_attempts" json:"max_attempts"`
+	MongoID      primitive.ObjectID `bson:"_id" json:"-"`
+	Priority     int                `bson:"priority" json:"priority"`
+	Results      []ModuleResult     `bson:"results" json:"results"`
+	RunAfter     time.Time          `bson:"run_after" json:"run_after"`
+	Status       QueueStatus        `bson:"status" json:"status"`
+	UpdateDate   time.Time          `bson:"update_date" json:"update_date"`
+}
+
+// ModuleResult is the outcome of a module run on an anime
+// Error is set when the run failed, Errors lists the problems of a run that
+// went on anyway, like an episode source not found
+// Confidence is the score of the match, from 0 to 1
+type ModuleResult struct {
+	Confidence      float64   `bson:"confidence" json:"confidence"`
+	EndDate         time.Time `bson:"end_date" json:"end_date"`
+	Episodes        int       `bson:"episodes" json:"episodes"`
+	Error           string    `bson:"error" json:"error"`
+	Errors          []string  `bson:"errors" json:"errors"`
+	Match           string    `bson:"match" json:"match"`
+	Matchings       int       `bson:"matchings" json:"matchings"`
+	Module          string    `bson:"module" json:"module"`
+	NewEpisodes     int       `bson:"new_episodes" json:"new_episodes"`
+	StartDate       time.Time `bson:"start_date" json:"start_date"`
+	UpdatedEpisodes int       `bson:"updated_episodes" json:"updated_episodes"`
+}
+
+// QueueCollectionName is a string value of queue MongoDB collection name
+var QueueCollectionName string = "queue"
+
+// QueueMaxAttempts is the number of attempts before an item is dead-lettered
+var QueueMaxAttempts = utils.GetEnvInt("QUEUE_MAX_ATTEMPTS", 3)
+
+// QueueRetryDelay is the delay before the first retry of a failed item
+// It doubles on every following attempt
+var QueueRetryDelay = 1 * time.Minute
+
+// EnqueueAnime adds an anime to the queue
+// When the anime is already queued, the existing item is returned and its
+// priority is raised if needed; an id is taken only for a new item
+func EnqueueAnime(animeID int, priority int) (*QueueItem, error) {
+	priority = ClampQueuePriority(priority)
+
+	q, err := store.Queue.GetActive(animeID)
+
+	if err == nil {
+		return raiseQueuePriority(q, priority)
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+
+	id, err := store.Counters.Next(QueueCollectionName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	q = &QueueItem{
+		Active:       true,
+		AnimeID:      animeID,
+		CreationDate: now,
+		ID:           id,
+		MaxAttempts:  QueueMaxAttempts,
+		MongoID:      primitive.NewObjectID(),
+		Priority:     priority,
+		RunAfter:     now,
+		Status:       QueueStatusPending,
+		UpdateDate:   now,
+	}
+
+	err = store.Queue.Insert(q)
+
+	if err != ErrDuplicate {
+		return q, err
+	}
+
+	// the anime has been queued meanwhile
+	q, err = store.Queue.GetActive(animeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return raiseQueuePriority(q, priority)
+}
+
+// raiseQueuePriority raises the priority of a pending item when lower
+func raiseQueuePriority(q *QueueItem, priority int) (*QueueItem, error) {
+	if q.Status != QueueStatusPending || q.Priority >= priority {
+		return q, nil
+	}
+
+	err := q.SetPriority(priority)
+
+	if err == ErrNotFound {
+		return store.Queue.GetActive(q.AnimeID)
+	}
+
+	return q, err
+}
+
+// GetQueueItem returns an existing queue item
+func GetQueueItem(id int) (*QueueItem, error) {
+	return store.Queue.Get(id)
+}
+
+// FindQueueItems returns the queue items having a status, or every item
+// when the status is empty
+func FindQueueItems(status QueueStatus, page *utils.PageInfo) ([]QueueItem, error) {
+	return store.Queue.Find(status, page)
+}
+
+// ClaimQueueItem atomically moves the next runnable item to the running status
+// It returns ErrNotFound when no item is runnable
+func ClaimQueueItem() (*QueueItem, error) {
+	return store.Queue.Claim(time.Now())
+}
+
+// ReleaseQueueItems moves back to pending every item left running
+// Should be called at startup, before any worker is started
+func ReleaseQueueItems() (int, error) {
+	return store.Queue.Release()
+}
+
+// IsValidQueueStatus checks if a status is a known queue item status
+func IsValidQueueStatus(status QueueStatus) bool {
+	switch status {
+	case QueueStatusPending, QueueStatusRunning, QueueStatusCompleted, QueueStatusFailed, QueueStatusCancelled:
+		return true
+	}
+
+	return false
+}
+
+// ClampQueuePriority returns a priority within the queue priorities range
+func ClampQueuePriority(priority int) int {
+	if priority < QueuePriorityNormal {
+		return QueuePriorityNormal
+	}
+
+	if priority > QueuePriorityHigh {
+		return QueuePriorityHigh
+	}
+
+	return priority
+}
+
+// SetPriority changes the priority of a pending queue item
+// It returns ErrNotFound when the item is not pending anymore
+func (q *QueueItem) SetPriority(priority int) error {
+	q.Priority = ClampQueuePriority(priority)
+	q.UpdateDate = time.Now()
+
+	return store.Queue.UpdatePending(q)
+}
+
+// Cancel removes a pending queue item from the queue
+// It returns ErrNotFound when the item is not pending anymore
+func (q *QueueItem) Cancel() error {
+	q.Active = false
+	q.Status = QueueStatusCancelled
+	q.UpdateDate = time.Now()
+
+	return store.Queue.UpdatePending(q)
+}
+
+// Stop marks a running queue item as cancelled, keeping the results of its run
+func (q *QueueItem) Stop() error {
+	q.Active = false
+	q.Status = QueueStatusCancelled
+
+	return q.save()
+}
+
+// Complete marks a queue item as processed
+func (q *QueueItem) Complete() error {
+	q.Active = false
+	q.LastError = ""
+	q.Status = QueueStatusCompleted
+
+	return q.save()
+}
+
+// Fail records a failed attempt of a queue item
+// The item is retried with an exponential delay until it runs out of
+// attempts, then it is dead-lettered
+func (q *QueueItem) Fail(cause error) error {
+	q.LastError = cause.Error()
+
+	if q.Attempts >= q.MaxAttempts {
+		q.Active = false
+		q.Status = QueueStatusFailed
+	} else {
+		q.Status = QueueStatusPending
+		q.RunAfter = time.Now().Add(QueueRetryDelay << uint(q.Attempts-1))
+	}
+
+	return q.save()
+}
+
+func (q *QueueItem) save() error {
+	q.UpdateDate = time.Now()
+
+	return store.Queue.Update(q)
+}
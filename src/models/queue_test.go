@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+// TestEnqueueAnimeDuplicate checks that queueing an anime twice keeps its
+// item, raising its priority, without taking a new id
+func TestEnqueueAnimeDuplicate(t *testing.T) {
+	SetStore(NewMemoryStore())
+
+	first, err := EnqueueAnime(1, QueuePriorityNormal)
+
+	if err != nil {
+		t.Fatalf("anime not queued: %s", err.Error())
+	}
+
+	again, err := EnqueueAnime(1, QueuePriorityHigh)
+
+	if err != nil {
+		t.Fatalf("anime not queued again: %s", err.Error())
+	}
+
+	if again.ID != first.ID || again.Priority != QueuePriorityHigh {
+		t.Errorf("expected item %d with priority %d, got item %d with priority %d", first.ID, QueuePriorityHigh, again.ID, again.Priority)
+	}
+
+	other, err := EnqueueAnime(2, QueuePriorityNormal)
+
+	if err != nil {
+		t.Fatalf("other anime not queued: %s", err.Error())
+	}
+
+	if other.ID != first.ID+1 {
+		t.Errorf("expected id %d, got %d", first.ID+1, other.ID)
+	}
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// Refresh intervals used to schedule anime re-scrapes
+var (
+	// RefreshAiring is the interval between airing anime refreshes
+	RefreshAiring = 12 * time.Hour
+	// RefreshRecentlyFinished is the interval between refreshes of an anime finished less than a month ago
+	RefreshRecentlyFinished = 3 * 24 * time.Hour
+	// RefreshFinished is the interval between finished anime refreshes
+	RefreshFinished = 60 * 24 * time.Hour
+	// RefreshNotYet is the longest interval between not yet aired anime refreshes
+	RefreshNotYet = 7 * 24 * time.Hour
+	// RefreshUnknown is the interval between refreshes of an anime without status
+	RefreshUnknown = 14 * 24 * time.Hour
+	// RefreshRetry is the interval before retrying a failed refresh
+	RefreshRetry = 1 * time.Hour
+)
+
+// NextRefreshDate returns when an anime should be re-scraped, based on its
+// status, airing dates and last update:
+// - airing anime are refreshed often
+// - finished anime are refreshed rarely, unless they finished recently
+// - not yet aired anime are refreshed near their airing start
+func (a *Anime) NextRefreshDate() time.Time {
+	last := a.UpdateDate
+
+	if last.IsZero() {
+		last = a.CreationDate
+	}
+
+	switch a.Status {
+	case AnimeStatusAiring:
+		return last.Add(RefreshAiring)
+	case AnimeStatusFinished:
+		if !a.AiringEnd.IsZero() && last.Sub(a.AiringEnd) < 30*24*time.Hour {
+			return last.Add(RefreshRecentlyFinished)
+		}
+
+		return last.Add(RefreshFinished)
+	case AnimeStatusNotYet:
+		next := last.Add(RefreshNotYet)
+
+		if !a.AiringStart.IsZero() && a.AiringStart.Before(next) {
+			next = a.AiringStart
+		}
+
+		if next.Before(last.Add(RefreshAiring)) {
+			next = last.Add(RefreshAiring)
+		}
+
+		return next
+	}
+
+	return last.Add(RefreshUnknown)
+}
+
+// IsRefreshDue checks if an anime should be re-scraped now
+func (a *Anime) IsRefreshDue() bool {
+	return !a.NextRefresh.After(time.Now())
+}
+
+// Postpone delays an anime next refresh, without touching other fields
+func (a *Anime) Postpone(d time.Duration) error {
+	a.NextRefresh = time.Now().Add(d)
+
+	return store.Animes.SetNextRefresh(a.ID, a.NextRefresh)
+}
+
+// FindDueAnimes returns the animes to be re-scraped, oldest due first
+func FindDueAnimes(limit int) ([]Anime, error) {
+	return store.Animes.FindDue(time.Now(), limit)
+}
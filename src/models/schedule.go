@@ -0,0 +1,164 @@
+package models
+
+import (
+	"aniapi-go/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Broadcast is a parsed MAL broadcast, like "Saturdays at 01:00 (JST)"
+type Broadcast struct {
+	Hour     int
+	Location *time.Location
+	Minute   int
+	Weekday  time.Weekday
+}
+
+// ScheduledEpisode is the next expected episode of an anime
+type ScheduledEpisode struct {
+	AiringAt time.Time `json:"airing_at"`
+	Episode  int       `json:"episode"`
+}
+
+var broadcastRegex = regexp.MustCompile(`^(\w+?)s? at (\d{1,2}):(\d{2}) \((\w+)\)$`)
+
+var broadcastLocations = map[string]*time.Location{
+	"JST": loadLocation("Asia/Tokyo", 9),
+	"KST": loadLocation("Asia/Seoul", 9),
+	"CST": loadLocation("Asia/Shanghai", 8),
+	"UTC": time.UTC,
+}
+
+// loadLocation loads a time zone, falling back to a fixed offset when the
+// time zone database is not available
+func loadLocation(name string, offset int) *time.Location {
+	loc, err := time.LoadLocation(name)
+
+	if err != nil {
+		return time.FixedZone(name, offset*60*60)
+	}
+
+	return loc
+}
+
+// ParseBroadcast parses a MAL broadcast string
+// It returns false for unknown or irregular broadcasts
+func ParseBroadcast(s string) (*Broadcast, bool) {
+	m := broadcastRegex.FindStringSubmatch(strings.TrimSpace(s))
+
+	if m == nil {
+		return nil, false
+	}
+
+	weekday := -1
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), m[1]) {
+			weekday = int(d)
+		}
+	}
+
+	loc, ok := broadcastLocations[strings.ToUpper(m[4])]
+
+	if weekday == -1 || !ok {
+		return nil, false
+	}
+
+	hour, _ := strconv.Atoi(m[2])
+	minute, _ := strconv.Atoi(m[3])
+
+	if hour > 23 || minute > 59 {
+		return nil, false
+	}
+
+	return &Broadcast{
+		Hour:     hour,
+		Location: loc,
+		Minute:   minute,
+		Weekday:  time.Weekday(weekday),
+	}, true
+}
+
+// Next returns the first broadcast time after a date
+func (b *Broadcast) Next(after time.Time) time.Time {
+	local := after.In(b.Location)
+	days := (int(b.Weekday) - int(local.Weekday()) + 7) % 7
+
+	next := time.Date(local.Year(), local.Month(), local.Day()+days, b.Hour, b.Minute, 0, 0, b.Location)
+
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 7)
+	}
+
+	return next
+}
+
+// NextEpisode returns the next expected episode of an airing or upcoming anime
+// Sources are, in order of precedence:
+// - the MAL broadcast weekday and time
+// - the AniList next airing episode
+// - a weekly schedule from the airing start date
+// It returns false when no episode is expected
+func (a *Anime) NextEpisode(now time.Time) (*ScheduledEpisode, bool) {
+	if a.Status != AnimeStatusAiring && a.Status != AnimeStatusNotYet {
+		return nil, false
+	}
+
+	start, startKnown := a.AiringStart, a.AiringStartPrecision == utils.DatePrecisionDay
+	next := &ScheduledEpisode{}
+
+	if b, ok := ParseBroadcast(a.Broadcast); ok {
+		after := now
+
+		if startKnown && start.After(after) {
+			after = start.Add(-time.Second)
+		}
+
+		next.AiringAt = b.Next(after)
+		next.Episode = a.episodeAiringAt(next.AiringAt, start, startKnown)
+	} else if a.NextAiringEpisode != nil && a.NextAiringEpisode.AiringAt.After(now) {
+		next.AiringAt = a.NextAiringEpisode.AiringAt
+		next.Episode = a.NextAiringEpisode.Episode
+	} else if startKnown {
+		next.AiringAt = start
+
+		for !next.AiringAt.After(now) {
+			next.AiringAt = next.AiringAt.AddDate(0, 0, 7)
+		}
+
+		next.Episode = a.episodeAiringAt(next.AiringAt, start, startKnown)
+	} else {
+		return nil, false
+	}
+
+	if a.AiringEndPrecision == utils.DatePrecisionDay && next.AiringAt.After(a.AiringEnd.AddDate(0, 0, 1)) {
+		return nil, false
+	}
+
+	if a.Episodes > 0 && next.Episode > a.Episodes {
+		return nil, false
+	}
+
+	return next, true
+}
+
+// episodeAiringAt returns the number of the episode airing at a date
+// The AniList next airing episode is trusted when it airs the same day,
+// otherwise a weekly schedule from the airing start is assumed
+func (a *Anime) episodeAiringAt(date time.Time, start time.Time, startKnown bool) int {
+	if a.NextAiringEpisode != nil {
+		diff := a.NextAiringEpisode.AiringAt.Sub(date)
+
+		if diff < 24*time.Hour && diff > -24*time.Hour {
+			return a.NextAiringEpisode.Episode
+		}
+	}
+
+	if !startKnown || date.Before(start) {
+		return 1
+	}
+
+	return int(date.Sub(start).Hours()/(24*7)) + 1
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"aniapi-go/utils"
+	"strings"
+	"time"
+)
+
+var animeSeasons = []AnimeSeason{SeasonWinter, SeasonSpring, SeasonSummer, SeasonFall}
+
+// ParseAnimeSeason converts a season name into the model one
+func ParseAnimeSeason(s string) (AnimeSeason, bool) {
+	for _, season := range animeSeasons {
+		if strings.EqualFold(string(season), s) {
+			return season, true
+		}
+	}
+
+	return "", false
+}
+
+// GetSeason returns the season of a date
+func GetSeason(t time.Time) AnimeSeason {
+	return animeSeasons[(int(t.Month())-1)/3]
+}
+
+// Dates returns the first day of a season and the first day of the next one
+func (s AnimeSeason) Dates(year int) (time.Time, time.Time) {
+	month := time.January
+
+	for i, season := range animeSeasons {
+		if season == s {
+			month = time.Month(i*3 + 1)
+		}
+	}
+
+	start := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 3, 0)
+}
+
+// AirsBetween checks if an anime airs at least once between two dates
+// Animes without a known airing end are considered still airing only
+// when their status is airing
+func (a *Anime) AirsBetween(start time.Time, end time.Time) bool {
+	if !isPreciseDate(a.AiringStartPrecision) || !a.AiringStart.Before(end) {
+		return false
+	}
+
+	if isPreciseDate(a.AiringEndPrecision) {
+		return !a.AiringEnd.Before(start)
+	}
+
+	return a.Status == AnimeStatusAiring
+}
+
+// isPreciseDate checks if a date is known at least to the month
+func isPreciseDate(p utils.DatePrecision) bool {
+	return p == utils.DatePrecisionMonth || p == utils.DatePrecisionDay
+}
+
+// FindAnimesBySeason returns every anime of a season, or airing during it
+// Animes still airing also match the seasons following their own one
+func FindAnimesBySeason(season AnimeSeason, year int) ([]Anime, error) {
+	start, end := season.Dates(year)
+	return store.Animes.FindBySeason(season, year, start, end)
+}
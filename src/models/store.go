@@ -0,0 +1,130 @@
+package models
+
+import (
+	"aniapi-go/utils"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by repositories when no document matches a lookup
+var ErrNotFound = errors.New("document not found")
+
+// ErrDuplicate is returned by repositories when a write breaks a unique constraint
+var ErrDuplicate = errors.New("duplicate document")
+
+// AnimeRepository is the storage interface of anime models
+type AnimeRepository interface {
+	Get(id int) (*Anime, error)
+	GetByMALID(malID int) (*Anime, error)
+	Find(title string, genres []string, showType string, page *utils.PageInfo, sort string, desc bool) ([]Anime, error)
+	FindByIDs(ids []int) ([]Anime, error)
+	FindByStatus(statuses []AnimeStatus) ([]Anime, error)
+	FindBySeason(season AnimeSeason, year int, start time.Time, end time.Time) ([]Anime, error)
+	Insert(a *Anime) error
+	Update(a *Anime) error
+	SetNextRefresh(id int, next time.Time) error
+	FindDue(now time.Time, limit int) ([]Anime, error)
+	MaxID() (int, error)
+	Collisions(field string) ([]AnimeCollision, error)
+	EnsureIndexes() error
+}
+
+// EpisodeRepository is the storage interface of episode models
+type EpisodeRepository interface {
+	Get(animeID int, number int, region string) (*Episode, error)
+	GetByKey(animeID int, from string, region EpisodeRegion, number int) (*Episode, error)
+	Find(animeID int, number int, from string, region string, page *utils.PageInfo, sort string, desc bool) ([]Episode, error)
+	LatestNumbers(animeIDs []int) (map[int]int, error)
+	Insert(e *Episode) error
+	Update(e *Episode) error
+}
+
+// MatchingRepository is the storage interface of matching models
+type MatchingRepository interface {
+	GetByKey(animeID int, from string, title string) (*Matching, error)
+	Find(animeID int, from string, sort string, desc bool) ([]Matching, error)
+	Insert(m *Matching) error
+	Update(m *Matching) error
+	IncreaseVotes(animeID int, from string, title string) error
+}
+
+// NotificationRepository is the storage interface of notification models
+type NotificationRepository interface {
+	Find(f NotificationFilter) ([]Notification, error)
+	Insert(n *Notification) error
+}
+
+// CounterRepository is the storage interface of atomic sequences
+type CounterRepository interface {
+	Next(name string) (int, error)
+	Sync(name string, value int) error
+}
+
+// CheckpointRepository is the storage interface of checkpoint models
+type CheckpointRepository interface {
+	Get(name string) (*Checkpoint, error)
+	Upsert(c *Checkpoint) error
+	Delete(name string) error
+}
+
+// QueueRepository is the storage interface of queue item models
+type QueueRepository interface {
+	Get(id int) (*QueueItem, error)
+	GetActive(animeID int) (*QueueItem, error)
+	Find(status QueueStatus, page *utils.PageInfo) ([]QueueItem, error)
+	Insert(q *QueueItem) error
+	Update(q *QueueItem) error
+	UpdatePending(q *QueueItem) error
+	Claim(now time.Time) (*QueueItem, error)
+	Release() (int, error)
+}
+
+// WebhookRepository is the storage interface of webhook models
+type WebhookRepository interface {
+	Get(id int) (*Webhook, error)
+	Find() ([]Webhook, error)
+	Insert(w *Webhook) error
+	Delete(id int) error
+}
+
+// WebhookDeliveryRepository is the storage interface of webhook delivery models
+type WebhookDeliveryRepository interface {
+	Find(webhookID int, status DeliveryStatus, page *utils.PageInfo) ([]WebhookDelivery, error)
+	FindDue(now time.Time, limit int) ([]WebhookDelivery, error)
+	Insert(d *WebhookDelivery) error
+	Update(d *WebhookDelivery) error
+}
+
+// ModuleSettingsRepository is the storage interface of module settings models
+type ModuleSettingsRepository interface {
+	Find() ([]ModuleSettingsPatch, error)
+	Upsert(s *ModuleSettingsPatch) error
+	Delete(name string) error
+}
+
+// Store groups all the repositories used by models
+type Store struct {
+	Counters          CounterRepository
+	Animes            AnimeRepository
+	Episodes          EpisodeRepository
+	Matchings         MatchingRepository
+	Notifications     NotificationRepository
+	Checkpoints       CheckpointRepository
+	Queue             QueueRepository
+	Webhooks          WebhookRepository
+	WebhookDeliveries WebhookDeliveryRepository
+	ModuleSettings    ModuleSettingsRepository
+}
+
+var store *Store = NewMongoStore()
+
+// SetStore replaces the storage backend used by models
+// Should be called before any model is read or saved
+func SetStore(s *Store) {
+	store = s
+}
+
+// GetStore returns the storage backend used by models
+func GetStore() *Store {
+	return store
+}
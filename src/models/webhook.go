@@ -0,0 +1,221 @@
+package models
+
+import (
+	"aniapi-go/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending means the delivery waits for its next attempt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered means the receiver accepted the delivery
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed means the delivery ran out of attempts
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Webhook is the MongoDB model of a webhook subscription document
+// Empty filters match every notification
+type Webhook struct {
+	AnilistIDs   []int              `bson:"anilist_ids" json:"anilist_ids"`
+	AnimeIDs     []int              `bson:"anime_ids" json:"anime_ids"`
+	CreationDate time.Time          `bson:"creation_date" json:"creation_date"`
+	ID           int                `bson:"id" json:"id"`
+	MongoID      primitive.ObjectID `bson:"_id" json:"-"`
+	Secret       string             `bson:"secret" json:"secret,omitempty"`
+	Types        []NotificationType `bson:"types" json:"types"`
+	URL          string             `bson:"url" json:"url"`
+}
+
+// WebhookDelivery is the MongoDB model of a webhook delivery document
+type WebhookDelivery struct {
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	CreationDate   time.Time          `bson:"creation_date" json:"creation_date"`
+	Error          string             `bson:"error" json:"error"`
+	ID             int                `bson:"id" json:"id"`
+	MongoID        primitive.ObjectID `bson:"_id" json:"-"`
+	NextAttempt    time.Time          `bson:"next_attempt" json:"next_attempt"`
+	Payload        string             `bson:"payload" json:"payload"`
+	ResponseStatus int                `bson:"response_status" json:"response_status"`
+	Status         DeliveryStatus     `bson:"status" json:"status"`
+	UpdateDate     time.Time          `bson:"update_date" json:"update_date"`
+	WebhookID      int                `bson:"webhook_id" json:"webhook_id"`
+}
+
+// WebhookCollectionName is a string value of webhooks MongoDB collection name
+var WebhookCollectionName string = "webhooks"
+
+// WebhookDeliveryCollectionName is a string value of webhook deliveries MongoDB collection name
+var WebhookDeliveryCollectionName string = "webhook_deliveries"
+
+// WebhookMaxAttempts is the number of attempts before a delivery fails
+var WebhookMaxAttempts = utils.GetEnvInt("WEBHOOK_MAX_ATTEMPTS", 6)
+
+// WebhookRetryDelay is the delay before the first retry of a delivery
+// It doubles on every following attempt
+var WebhookRetryDelay = 30 * time.Second
+
+// GetWebhook returns an existing webhook model
+func GetWebhook(id int) (*Webhook, error) {
+	return store.Webhooks.Get(id)
+}
+
+// FindWebhooks returns every webhook model
+func FindWebhooks() ([]Webhook, error) {
+	return store.Webhooks.Find()
+}
+
+// DeleteWebhook removes a webhook model from the store
+func DeleteWebhook(id int) error {
+	return store.Webhooks.Delete(id)
+}
+
+// IsValid checks if a webhook model has the following props:
+// - an http or https url
+// - known notification types
+func (w *Webhook) IsValid() bool {
+	u, err := url.Parse(w.URL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+
+	for _, t := range w.Types {
+		if t != TypeAnimeChange && t != TypeEpisodeChange {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckHost resolves the webhook url host and returns an error when it is
+// not a public address, so that webhooks can not reach internal services
+func (w *Webhook) CheckHost() error {
+	u, err := url.Parse(w.URL)
+
+	if err != nil {
+		return err
+	}
+
+	return utils.CheckPublicHost(u.Hostname())
+}
+
+// Save creates a webhook model on the store
+// A random secret is generated when the webhook has none
+func (w *Webhook) Save() error {
+	if w.Secret == "" {
+		secret := make([]byte, 32)
+
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+
+		w.Secret = hex.EncodeToString(secret)
+	}
+
+	id, err := store.Counters.Next(WebhookCollectionName)
+
+	if err != nil {
+		return err
+	}
+
+	w.ID = id
+	w.MongoID = primitive.NewObjectID()
+	w.CreationDate = time.Now()
+
+	return store.Webhooks.Insert(w)
+}
+
+// Matches checks if a notification matches the webhook filters
+func (w *Webhook) Matches(n Notification) bool {
+	if len(w.Types) > 0 {
+		found := false
+
+		for _, t := range w.Types {
+			if t == n.Type {
+				found = true
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(w.AnimeIDs) == 0 && len(w.AnilistIDs) == 0 {
+		return true
+	}
+
+	return containsInt(w.AnimeIDs, n.AnimeID) || containsInt(w.AnilistIDs, n.AnilistID)
+}
+
+// NewWebhookDelivery creates a pending delivery of a payload to a webhook
+func NewWebhookDelivery(webhookID int, payload string) (*WebhookDelivery, error) {
+	id, err := store.Counters.Next(WebhookDeliveryCollectionName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	d := &WebhookDelivery{
+		CreationDate: now,
+		ID:           id,
+		MongoID:      primitive.NewObjectID(),
+		NextAttempt:  now,
+		Payload:      payload,
+		Status:       DeliveryPending,
+		UpdateDate:   now,
+		WebhookID:    webhookID,
+	}
+
+	return d, store.WebhookDeliveries.Insert(d)
+}
+
+// FindWebhookDeliveries returns the deliveries of a webhook, from the most recent
+func FindWebhookDeliveries(webhookID int, status DeliveryStatus, page *utils.PageInfo) ([]WebhookDelivery, error) {
+	return store.WebhookDeliveries.Find(webhookID, status, page)
+}
+
+// FindDueWebhookDeliveries returns the pending deliveries whose next attempt is due
+func FindDueWebhookDeliveries(limit int) ([]WebhookDelivery, error) {
+	return store.WebhookDeliveries.FindDue(time.Now(), limit)
+}
+
+// Delivered records a successful delivery attempt
+func (d *WebhookDelivery) Delivered(status int) error {
+	d.Attempts++
+	d.Error = ""
+	d.ResponseStatus = status
+	d.Status = DeliveryDelivered
+	d.UpdateDate = time.Now()
+
+	return store.WebhookDeliveries.Update(d)
+}
+
+// Fail records a failed delivery attempt
+// The delivery is retried with an exponential delay until it runs out of attempts
+func (d *WebhookDelivery) Fail(status int, cause error) error {
+	d.Attempts++
+	d.Error = cause.Error()
+	d.ResponseStatus = status
+	d.UpdateDate = time.Now()
+
+	if d.Attempts >= WebhookMaxAttempts {
+		d.Status = DeliveryFailed
+	} else {
+		d.NextAttempt = d.UpdateDate.Add(WebhookRetryDelay << uint(d.Attempts-1))
+	}
+
+	return store.WebhookDeliveries.Update(d)
+}
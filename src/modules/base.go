@@ -3,20 +3,25 @@ package modules
 import (
 	"aniapi-go/models"
 	"aniapi-go/utils"
-	"errors"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"math"
-	"net/http"
+	"net/url"
+	"reflect"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
 // Module is the basic interface for a module
+// Start returns an error when the module could not complete its flow, like
+// when the search or the episodes page can not be fetched or the context is
+// done; the result, when not nil, holds what has been done until then
 type Module interface {
-	Start(a *models.Anime)
-	GetList(title string) *goquery.Selection
+	Start(ctx context.Context, a *models.Anime) (*Result, error)
+	GetList(ctx context.Context, title string) (*goquery.Selection, error)
 	GetTarget(s *goquery.Selection) string
 	GetEpisodesNumber(s *goquery.Selection) int
 	GetURL(s *goquery.Selection) string
@@ -24,103 +29,197 @@ type Module interface {
 	GetMatches(animeID int) []models.Matching
 }
 
-// ModuleScrapeURL tries to parse an URI HTML
-func ModuleScrapeURL(url string) (*goquery.Document, error) {
-	transport := &http.Transport{
-		Proxy: utils.GetBestProxy,
+// Result is the outcome of a module scraping flow on an anime
+type Result struct {
+	Confidence float64
+	Episodes   int
+	Errors     []string
+	Match      string
+	New        int
+	Updated    int
+}
+
+// NamedModule is a module whose name is not its type name
+type NamedModule interface {
+	Name() string
+}
+
+// ModuleName returns the name of a module, which is its lowercase type name
+// unless the module has its own name
+func ModuleName(m Module) string {
+	if n, ok := m.(NamedModule); ok {
+		return n.Name()
 	}
 
-	client := http.Client{
-		Transport: transport,
+	t := reflect.TypeOf(m)
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	return strings.ToLower(t.Name())
+}
+
+// SaveEpisode saves an episode found by a module, counting it as new or updated
+func (r *Result) SaveEpisode(e *models.Episode) error {
+	created, err := e.Save()
 
 	if err != nil {
-		log.Printf("ERRORE: %s", err.Error())
+		r.AddError("episode %d not saved: %s", e.Number, err.Error())
+		return err
 	}
 
-	resp, err := client.Do(req)
+	r.Episodes++
+
+	if created {
+		r.New++
+	} else {
+		r.Updated++
+	}
+
+	return nil
+}
+
+// AddError records a problem which did not stop the module flow
+func (r *Result) AddError(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// ModuleScrapeURL tries to parse an URI HTML, through the proxies unless disabled
+// Temporary failures are retried by the shared fetchers until the context is done
+func ModuleScrapeURL(ctx context.Context, url string, proxy bool) (*goquery.Document, error) {
+	doc, err := moduleFetcher(proxy).GetDocumentContext(ctx, url)
 
 	if err != nil {
 		log.Printf("URL (%s) REQUEST ERROR: %s", url, err.Error())
 		return nil, err
 	}
 
-	if resp.StatusCode != 200 {
-		err = errors.New(resp.Status)
+	return doc, nil
+}
+
+// ModuleGetJSON fetches an URI and decodes its JSON body, through the
+// proxies unless disabled
+// Temporary failures are retried by the shared fetchers until the context is done
+func ModuleGetJSON(ctx context.Context, url string, proxy bool, v interface{}) error {
+	resp, err := moduleFetcher(proxy).GetContext(ctx, url)
+
+	if err != nil {
 		log.Printf("URL (%s) REQUEST ERROR: %s", url, err.Error())
-		return nil, errors.New(resp.Status)
+		return err
 	}
 
 	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	err = json.NewDecoder(resp.Body).Decode(v)
 
 	if err != nil {
-		log.Printf("URL (%s) READING ERROR: %s", url, err.Error())
-		return nil, err
+		log.Printf("URL (%s) JSON ERROR: %s", url, err.Error())
+		return err
 	}
 
-	return doc, nil
+	return nil
+}
+
+func moduleFetcher(proxy bool) *utils.Fetcher {
+	if proxy {
+		return utils.DefaultFetcher
+	}
+
+	return utils.DirectFetcher
 }
 
 // ModuleFuzzyWuzzy matches an anime model with a specific module search results
-func ModuleFuzzyWuzzy(m Module, titles []string, a *models.Anime) (string, int) {
-	match := ""
-	episodes := 0
-	best := 99
-	//ratio := 0.0
-	var otherMatches []*models.Matching
+// Every search result is scored against the anime titles: the best one is
+// chosen when its score reaches MatchThreshold, while the others reaching
+// CandidateThreshold are saved as possible matchings
+// Without a match, the most voted matching is chosen
+// It fails when nothing matched and a search could not be fetched, or when
+// the context is done
+func ModuleFuzzyWuzzy(ctx context.Context, m Module, titles []string, a *models.Anime) (Match, error) {
+	var match Match
+	var searchErr error
+
+	candidates := make(map[string]*models.Matching)
+	normalized := make([]Title, 0, len(titles))
 
 	for _, title := range titles {
-		list := m.GetList(title)
+		normalized = append(normalized, NormalizeTitle(title))
+	}
 
-		if list != nil {
-			list.Each(func(_ int, s *goquery.Selection) {
-				target := m.GetTarget(s)
-				source := strings.Replace(strings.ToLower(title), ":", "", -1)
+	for _, title := range titles {
+		list, err := m.GetList(ctx, title)
 
-				score := fuzzy.RankMatch(source, target)
+		if ctx.Err() != nil {
+			return Match{}, ctx.Err()
+		} else if err != nil {
+			searchErr = err
+		}
 
-				bigger := math.Max(float64(len(source)), float64(len(target)))
-				r := (bigger - float64(score)) / bigger
+		if list == nil {
+			continue
+		}
 
-				eps := m.GetEpisodesNumber(s)
+		list.Each(func(_ int, s *goquery.Selection) {
+			target := m.GetTarget(s)
+			url := m.GetURL(s)
 
-				if score < best && score != -1 && score <= 1 {
-					best = score
-					match = m.GetURL(s)
+			if url == "" {
+				return
+			}
 
-					episodes = eps
+			similarity := 0.0
+			normalizedTarget := NormalizeTitle(target)
 
-					//ratio = r
-				}
+			for _, t := range normalized {
+				similarity = math.Max(similarity, t.Similarity(normalizedTarget))
+			}
+
+			eps := m.GetEpisodesNumber(s)
+			year, kind := 0, ""
 
-				if (score > 1 && len(source) > 2) || (len(source) <= 2 && score > 1 && score <= 10) {
-					url := m.GetURL(s)
-					otherMatches = append(otherMatches, m.AddToMatches(a.ID, eps, r, target, url))
+			if d, ok := m.(DetailedModule); ok {
+				year, kind = d.GetYear(s), d.GetType(s)
+			}
+
+			score := ScoreMatch(a, similarity, eps, year, kind)
+
+			if score >= MatchThreshold && score > match.Confidence {
+				match = Match{
+					Confidence: score,
+					Episodes:   eps,
+					URL:        url,
 				}
-			})
-		}
+			}
 
-		if match == "" {
-			for _, m := range otherMatches {
-				m.Save()
+			if c, ok := candidates[url]; score >= CandidateThreshold && (!ok || score > c.Ratio) {
+				candidates[url] = m.AddToMatches(a.ID, eps, score, target, url)
 			}
-		}
+		})
 	}
 
-	if match == "" {
+	for _, c := range candidates {
+		c.Save()
+	}
+
+	// Matchings store absolute urls, modules expect the search result path
+	if match.URL == "" {
 		matches := m.GetMatches(a.ID)
 
-		if matches != nil && len(matches) > 0 {
-			if matches[0].Votes > 0 {
-				match = "/" + strings.Join(strings.Split(matches[0].URL, "/")[3:5], "/")
-				episodes = matches[0].Episodes
+		if len(matches) > 0 && matches[0].Votes > 0 {
+			if u, err := url.Parse(matches[0].URL); err == nil {
+				match = Match{
+					Confidence: matches[0].Ratio,
+					Episodes:   matches[0].Episodes,
+					URL:        u.RequestURI(),
+				}
 			}
 		}
 	}
 
-	return match, episodes
+	if match.URL == "" && searchErr != nil {
+		return Match{}, fmt.Errorf("search failed: %s", searchErr.Error())
+	}
+
+	return match, nil
 }
@@ -0,0 +1,438 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"gopkg.in/yaml.v2"
+)
+
+// Definition is the YAML or JSON description of a declarative module
+type Definition struct {
+	BaseURL  string               `yaml:"base_url" json:"base_url"`
+	Episodes EpisodesDefinition   `yaml:"episodes" json:"episodes"`
+	Name     string               `yaml:"name" json:"name"`
+	Region   models.EpisodeRegion `yaml:"region" json:"region"`
+	Search   SearchDefinition     `yaml:"search" json:"search"`
+	Source   Selector             `yaml:"source" json:"source"`
+}
+
+// SearchDefinition describes the search page of a declarative module
+// The URL is a template where {query} is replaced by the escaped title
+// Episodes, Type and Year are optional, used to score the search results
+type SearchDefinition struct {
+	Episodes Selector `yaml:"episodes" json:"episodes"`
+	Item     string   `yaml:"item" json:"item"`
+	Link     Selector `yaml:"link" json:"link"`
+	Title    Selector `yaml:"title" json:"title"`
+	Type     Selector `yaml:"type" json:"type"`
+	URL      string   `yaml:"url" json:"url"`
+	Year     Selector `yaml:"year" json:"year"`
+}
+
+// EpisodesDefinition describes the episodes list of the matched anime page
+// Episodes without a number are numbered by their position in the list
+type EpisodesDefinition struct {
+	Item   string   `yaml:"item" json:"item"`
+	Link   Selector `yaml:"link" json:"link"`
+	Number Selector `yaml:"number" json:"number"`
+	Title  Selector `yaml:"title" json:"title"`
+}
+
+// Selector extracts a value from an HTML element:
+// - Query is a CSS selector relative to the element, the element itself when empty
+// - Attr is the attribute to read, the text when empty
+// - Pattern is a regular expression applied to the value, keeping the first group
+type Selector struct {
+	Attr    string `yaml:"attr" json:"attr"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Query   string `yaml:"query" json:"query"`
+	regex   *regexp.Regexp
+}
+
+// Declarative is a module driven by a definition instead of Go code
+type Declarative struct {
+	Definition *Definition
+	Settings   models.ModuleSettings
+	base       *url.URL
+}
+
+// Name returns the definition name, used as the episodes and matchings origin
+func (d Declarative) Name() string {
+	return d.Definition.Name
+}
+
+// Start the scraping flow
+func (d Declarative) Start(ctx context.Context, a *models.Anime) (*Result, error) {
+	titles := append([]string{a.MainTitle}, a.AlternativesTitle...)
+	match, err := ModuleFuzzyWuzzy(ctx, &d, titles, a)
+	result := &Result{Confidence: match.Confidence, Match: match.URL}
+
+	if err != nil || match.URL == "" {
+		return result, err
+	}
+
+	log.Printf("[%s] MATCHED %s ON %s WITH %d EPISODES (%.2f)", strings.ToUpper(d.Name()), a.MainTitle, match.URL, match.Episodes, match.Confidence)
+
+	return result, d.getEpisodes(ctx, d.resolve(match.URL), a, result)
+}
+
+// GetList retrieves search results list
+func (d Declarative) GetList(ctx context.Context, title string) (*goquery.Selection, error) {
+	query := d.resolve(strings.Replace(d.Definition.Search.URL, "{query}", url.QueryEscape(title), -1))
+	doc, err := ModuleScrapeURL(ctx, query, d.Settings.Proxy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Find(d.Definition.Search.Item), nil
+}
+
+// GetTarget retrieves search result title
+func (d Declarative) GetTarget(s *goquery.Selection) string {
+	return strings.ToLower(d.Definition.Search.Title.Extract(s))
+}
+
+// GetEpisodesNumber retrieves search result episodes number
+func (d Declarative) GetEpisodesNumber(s *goquery.Selection) int {
+	if d.Definition.Search.Episodes.IsEmpty() {
+		return 0
+	}
+
+	eps, _ := strconv.Atoi(d.Definition.Search.Episodes.Extract(s))
+	return eps
+}
+
+// GetYear retrieves search result release year
+func (d Declarative) GetYear(s *goquery.Selection) int {
+	if d.Definition.Search.Year.IsEmpty() {
+		return 0
+	}
+
+	year, _ := strconv.Atoi(d.Definition.Search.Year.Extract(s))
+	return year
+}
+
+// GetType retrieves search result anime type
+func (d Declarative) GetType(s *goquery.Selection) string {
+	if d.Definition.Search.Type.IsEmpty() {
+		return ""
+	}
+
+	return d.Definition.Search.Type.Extract(s)
+}
+
+// GetURL retrieves search result anime url
+func (d Declarative) GetURL(s *goquery.Selection) string {
+	return d.Definition.Search.Link.Extract(s)
+}
+
+// AddToMatches adds a search result to possible matchings
+func (d Declarative) AddToMatches(animeID int, episodes int, ratio float64, target string, url string) *models.Matching {
+	return &models.Matching{
+		AnimeID:  animeID,
+		Episodes: episodes,
+		From:     d.Name(),
+		Ratio:    ratio,
+		Title:    target,
+		URL:      d.resolve(url),
+	}
+}
+
+// GetMatches retrieves an anime model possible matchings
+func (d Declarative) GetMatches(animeID int) []models.Matching {
+	matchings, err := models.FindMatchings(animeID, d.Name(), "votes", true)
+
+	if err != nil {
+		return nil
+	}
+
+	return matchings
+}
+
+// getEpisodes saves every episode of the matched anime page
+// A page without episodes list is considered the page of its only episode
+func (d Declarative) getEpisodes(ctx context.Context, uri string, anime *models.Anime, result *Result) error {
+	doc, err := ModuleScrapeURL(ctx, uri, d.Settings.Proxy)
+
+	if err != nil {
+		return err
+	}
+
+	items := doc.Find(d.Definition.Episodes.Item)
+
+	if items.Length() == 0 {
+		d.saveEpisode(ctx, anime, 1, "", uri, result)
+		return ctx.Err()
+	}
+
+	items.EachWithBreak(func(i int, s *goquery.Selection) bool {
+		link := d.Definition.Episodes.Link.Extract(s)
+
+		if link == "" {
+			return true
+		}
+
+		number, _ := strconv.Atoi(d.Definition.Episodes.Number.Extract(s))
+
+		if d.Definition.Episodes.Number.IsEmpty() || number == 0 {
+			number = i + 1
+		}
+
+		d.saveEpisode(ctx, anime, number, d.Definition.Episodes.Title.Extract(s), d.resolve(link), result)
+
+		return ctx.Err() == nil
+	})
+
+	return ctx.Err()
+}
+
+func (d Declarative) saveEpisode(ctx context.Context, anime *models.Anime, number int, title string, uri string, result *Result) {
+	episode := &models.Episode{
+		AnimeID: anime.ID,
+		From:    d.Name(),
+		Number:  number,
+		Region:  d.Settings.Region,
+		Source:  uri,
+		Title:   title,
+	}
+
+	if !d.Definition.Source.IsEmpty() {
+		doc, err := ModuleScrapeURL(ctx, uri, d.Settings.Proxy)
+
+		if ctx.Err() != nil {
+			return
+		} else if err != nil {
+			result.AddError("episode %d source not fetched: %s", number, err.Error())
+			return
+		}
+
+		episode.Source = d.Definition.Source.Extract(doc.Selection)
+
+		if episode.Source == "" {
+			result.AddError("episode %d source not found", number)
+			return
+		}
+
+		episode.Source = d.resolve(episode.Source)
+	}
+
+	if result.SaveEpisode(episode) == nil {
+		log.Printf("[%s] SAVED EPISODE %d OF %s", strings.ToUpper(d.Name()), number, anime.MainTitle)
+	}
+}
+
+// resolve turns a link relative to the base url into an absolute one
+func (d Declarative) resolve(link string) string {
+	ref, err := url.Parse(link)
+
+	if err != nil {
+		return link
+	}
+
+	return d.base.ResolveReference(ref).String()
+}
+
+// Extract returns the value selected from an element, an empty string when
+// nothing is selected
+func (sel *Selector) Extract(s *goquery.Selection) string {
+	if sel.Query != "" {
+		s = s.Find(sel.Query).First()
+	}
+
+	if s.Length() == 0 {
+		return ""
+	}
+
+	value := s.Text()
+
+	if sel.Attr != "" {
+		value, _ = s.Attr(sel.Attr)
+	}
+
+	value = strings.TrimSpace(value)
+
+	if sel.regex != nil {
+		m := sel.regex.FindStringSubmatch(value)
+
+		if m == nil {
+			return ""
+		}
+
+		value = m[0]
+
+		if len(m) > 1 {
+			value = m[1]
+		}
+
+		value = strings.TrimSpace(value)
+	}
+
+	return value
+}
+
+// IsEmpty checks if a selector has not been defined
+func (sel *Selector) IsEmpty() bool {
+	return sel.Attr == "" && sel.Pattern == "" && sel.Query == ""
+}
+
+func (sel *Selector) compile(name string) error {
+	if sel.Pattern == "" {
+		return nil
+	}
+
+	regex, err := regexp.Compile(sel.Pattern)
+
+	if err != nil {
+		return fmt.Errorf("%s pattern: %s", name, err.Error())
+	}
+
+	sel.regex = regex
+	return nil
+}
+
+// Validate checks a definition and compiles its patterns
+func (def *Definition) Validate() error {
+	def.Name = strings.ToLower(strings.TrimSpace(def.Name))
+
+	if def.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	base, err := url.Parse(def.BaseURL)
+
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return fmt.Errorf("base_url must be an absolute http or https url")
+	}
+
+	if def.Region != models.RegionIT && def.Region != models.RegionEN {
+		return fmt.Errorf("region must be %s or %s", models.RegionIT, models.RegionEN)
+	}
+
+	if !strings.Contains(def.Search.URL, "{query}") {
+		return fmt.Errorf("search url must contain {query}")
+	}
+
+	if def.Search.Item == "" || def.Search.Link.IsEmpty() {
+		return fmt.Errorf("search item and link are required")
+	}
+
+	if def.Episodes.Item == "" || def.Episodes.Link.IsEmpty() {
+		return fmt.Errorf("episodes item and link are required")
+	}
+
+	selectors := map[string]*Selector{
+		"search.episodes": &def.Search.Episodes,
+		"search.link":     &def.Search.Link,
+		"search.title":    &def.Search.Title,
+		"search.type":     &def.Search.Type,
+		"search.year":     &def.Search.Year,
+		"episodes.link":   &def.Episodes.Link,
+		"episodes.number": &def.Episodes.Number,
+		"episodes.title":  &def.Episodes.Title,
+		"source":          &def.Source,
+	}
+
+	for name, sel := range selectors {
+		err = sel.compile(name)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewDeclarative creates a new declarative module from a valid definition
+// The base url and the region of the definition are the default settings
+// It returns an error when the base url is not an absolute http or https url
+func NewDeclarative(def *Definition, settings models.ModuleSettings) (Declarative, error) {
+	base, err := url.Parse(settings.BaseURL)
+
+	if err != nil {
+		return Declarative{}, err
+	}
+
+	if (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return Declarative{}, fmt.Errorf("base_url %s is not an absolute http or https url", settings.BaseURL)
+	}
+
+	return Declarative{
+		Definition: def,
+		Settings:   settings,
+		base:       base,
+	}, nil
+}
+
+// RegisterDefinitions registers a declarative module for every .yml, .yaml
+// and .json definition of a directory
+// Invalid definitions are logged and skipped, a missing directory means no module
+func RegisterDefinitions(dir string) {
+	files, err := ioutil.ReadDir(dir)
+
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		log.Printf("MODULE DEFINITIONS NOT LOADED FROM %s: %s", dir, err.Error())
+		return
+	}
+
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+
+		if f.IsDir() || (ext != ".yml" && ext != ".yaml" && ext != ".json") {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		def, err := loadDefinition(path)
+
+		if err == nil {
+			err = Register(def.Name, models.ModuleSettings{
+				BaseURL: def.BaseURL,
+				Enabled: true,
+				Proxy:   true,
+				Region:  def.Region,
+			}, func(settings models.ModuleSettings) (Module, error) {
+				return NewDeclarative(def, settings)
+			})
+		}
+
+		if err != nil {
+			log.Printf("MODULE DEFINITION %s NOT LOADED: %s", path, err.Error())
+			continue
+		}
+
+		log.Printf("MODULE %s LOADED FROM %s", def.Name, path)
+	}
+}
+
+// loadDefinition reads and validates a definition file, JSON being a subset of YAML
+func loadDefinition(path string) (*Definition, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	def := &Definition{}
+	err = yaml.UnmarshalStrict(data, def)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return def, def.Validate()
+}
@@ -0,0 +1,201 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// validDefinition returns a definition passing Validate, to be broken by
+// the test cases
+func validDefinition() *Definition {
+	return &Definition{
+		BaseURL: "https://anime.example.com",
+		Episodes: EpisodesDefinition{
+			Item: "#episodes li a",
+			Link: Selector{Attr: "href"},
+		},
+		Name:   "Example",
+		Region: models.RegionEN,
+		Search: SearchDefinition{
+			Item: ".results li",
+			Link: Selector{Query: ".name", Attr: "href"},
+			URL:  "/search?keyword={query}",
+		},
+	}
+}
+
+func TestDefinitionValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		edit  func(def *Definition)
+		error string
+	}{
+		{"valid", func(def *Definition) {}, ""},
+		{"missing name", func(def *Definition) { def.Name = " " }, "name is required"},
+		{"missing base_url", func(def *Definition) { def.BaseURL = "" }, "base_url"},
+		{"relative base_url", func(def *Definition) { def.BaseURL = "/anime" }, "base_url"},
+		{"base_url without scheme", func(def *Definition) { def.BaseURL = "anime.example.com" }, "base_url"},
+		{"ftp base_url", func(def *Definition) { def.BaseURL = "ftp://anime.example.com" }, "base_url"},
+		{"invalid base_url", func(def *Definition) { def.BaseURL = "https://anime example.com/%zz" }, "base_url"},
+		{"unknown region", func(def *Definition) { def.Region = "jp" }, "region"},
+		{"search url without query", func(def *Definition) { def.Search.URL = "/search" }, "{query}"},
+		{"missing search item", func(def *Definition) { def.Search.Item = "" }, "search item and link"},
+		{"missing search link", func(def *Definition) { def.Search.Link = Selector{} }, "search item and link"},
+		{"missing episodes item", func(def *Definition) { def.Episodes.Item = "" }, "episodes item and link"},
+		{"missing episodes link", func(def *Definition) { def.Episodes.Link = Selector{} }, "episodes item and link"},
+		{"invalid pattern", func(def *Definition) { def.Source = Selector{Pattern: "("} }, "source pattern"},
+	}
+
+	for _, c := range cases {
+		def := validDefinition()
+		c.edit(def)
+		err := def.Validate()
+
+		if c.error == "" && err != nil {
+			t.Errorf("%s: unexpected error %s", c.name, err.Error())
+		} else if c.error != "" && (err == nil || !strings.Contains(err.Error(), c.error)) {
+			t.Errorf("%s: expected error containing %q, got %v", c.name, c.error, err)
+		}
+	}
+}
+
+func TestNewDeclarative(t *testing.T) {
+	cases := map[string]bool{
+		"https://anime.example.com":      true,
+		"http://anime.example.com/base/": true,
+		"":                               false,
+		"anime.example.com":              false,
+		"/anime":                         false,
+		"ftp://anime.example.com":        false,
+		"https://anime example.com/%zz":  false,
+	}
+
+	for base, valid := range cases {
+		_, err := NewDeclarative(validDefinition(), models.ModuleSettings{BaseURL: base})
+
+		if valid != (err == nil) {
+			t.Errorf("%q: expected valid %t, got error %v", base, valid, err)
+		}
+	}
+}
+
+func TestSelectorExtract(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<ul>
+		<li data-id="7"><a class="name" href="/anime/cowboy-bebop"> Cowboy Bebop </a><span class="episodes">Episodes: 26</span></li>
+	</ul>`))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	item := doc.Find("li")
+
+	cases := []struct {
+		name     string
+		selector Selector
+		expected string
+	}{
+		{"element text", Selector{}, "Cowboy Bebop Episodes: 26"},
+		{"element attribute", Selector{Attr: "data-id"}, "7"},
+		{"query text", Selector{Query: ".name"}, "Cowboy Bebop"},
+		{"query attribute", Selector{Query: ".name", Attr: "href"}, "/anime/cowboy-bebop"},
+		{"pattern group", Selector{Query: ".episodes", Pattern: `(\d+)`}, "26"},
+		{"pattern without group", Selector{Query: ".episodes", Pattern: `Episodes`}, "Episodes"},
+		{"pattern not matching", Selector{Query: ".episodes", Pattern: `Year (\d+)`}, ""},
+		{"query not matching", Selector{Query: ".year"}, ""},
+		{"missing attribute", Selector{Query: ".name", Attr: "title"}, ""},
+	}
+
+	for _, c := range cases {
+		if err := c.selector.compile(c.name); err != nil {
+			t.Fatalf("%s: %s", c.name, err.Error())
+		}
+
+		if got := c.selector.Extract(item); got != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, got)
+		}
+	}
+}
+
+// TestDeclarativeEpisodes runs a module loaded from a definition file on
+// Cowboy Bebop using the fixtures of anime.example.com, hand-written stubs
+// shaped like a streaming site
+func TestDeclarativeEpisodes(t *testing.T) {
+	models.SetStore(models.NewMemoryStore())
+	transport := utils.UseFixtures("../fixtures", utils.FixtureReplay)
+
+	def, err := loadDefinition("testdata/example.yml")
+
+	if err != nil {
+		t.Fatalf("definition not loaded: %s", err.Error())
+	}
+
+	module, err := NewDeclarative(def, models.ModuleSettings{
+		BaseURL: def.BaseURL,
+		Proxy:   true,
+		Region:  def.Region,
+	})
+
+	if err != nil {
+		t.Fatalf("module not created: %s", err.Error())
+	}
+
+	anime := &models.Anime{
+		AiringStart:          time.Date(1998, time.April, 3, 0, 0, 0, 0, time.UTC),
+		AiringStartPrecision: utils.DatePrecisionDay,
+		Episodes:             26,
+		MainTitle:            "Cowboy Bebop",
+		Status:               models.AnimeStatusFinished,
+		Type:                 "TV",
+	}
+
+	if err := anime.Save(); err != nil {
+		t.Fatalf("anime not saved: %s", err.Error())
+	}
+
+	result, err := module.Start(context.Background(), anime)
+
+	if missing := transport.Missing(); len(missing) > 0 {
+		t.Fatalf("fixtures not found for:\n%s", strings.Join(missing, "\n"))
+	}
+
+	if err != nil {
+		t.Fatalf("module failed: %s", err.Error())
+	}
+
+	if len(result.Errors) > 0 {
+		t.Fatalf("module errors: %s", strings.Join(result.Errors, ", "))
+	}
+
+	if result.Match != "/anime/cowboy-bebop" || result.Confidence < MatchThreshold {
+		t.Errorf("expected /anime/cowboy-bebop to be matched, got %s (%.2f)", result.Match, result.Confidence)
+	}
+
+	episodes, err := models.FindEpisodes(anime.ID, 0, "example", "", utils.GetPageInfo(1), "number", false)
+
+	if err != nil {
+		t.Fatalf("episodes not found: %s", err.Error())
+	}
+
+	expected := []models.Episode{
+		{Number: 1, Source: "https://player.example.com/embed/cb01", Title: "Asteroid Blues"},
+		{Number: 2, Source: "https://player.example.com/embed/cb02", Title: "Stray Dog Strut"},
+		{Number: 3, Source: "https://anime.example.com/embed/cb03", Title: "Honky Tonk Women"},
+	}
+
+	if len(episodes) != len(expected) {
+		t.Fatalf("expected %d episodes, got %d", len(expected), len(episodes))
+	}
+
+	for i, e := range episodes {
+		if e.Number != expected[i].Number || e.Source != expected[i].Source || e.Title != expected[i].Title || e.Region != models.RegionEN {
+			t.Errorf("episode %d: expected %+v, got %+v", i+1, expected[i], e)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package modules
 
 import (
 	"aniapi-go/models"
+	"context"
 	"log"
 	"net/url"
 	"strconv"
@@ -11,42 +12,68 @@ import (
 )
 
 // Dreamsub is the https://dreamsub.stream/ module
-type Dreamsub struct{}
+type Dreamsub struct {
+	Settings models.ModuleSettings
+}
+
+func init() {
+	Register("dreamsub", models.ModuleSettings{
+		BaseURL: "https://dreamsub.stream",
+		Enabled: true,
+		Proxy:   true,
+		Region:  models.RegionIT,
+	}, func(settings models.ModuleSettings) (Module, error) {
+		return NewDreamsub(settings), nil
+	})
+}
 
 // Start the scraping flow
-func (d Dreamsub) Start(a *models.Anime) {
+func (d Dreamsub) Start(ctx context.Context, a *models.Anime) (*Result, error) {
 	titles := append([]string{a.MainTitle}, a.AlternativesTitle...)
-	match, count := ModuleFuzzyWuzzy(&d, titles, a)
-
-	if match != "" {
-		log.Printf("[DREAMSUB] MATCHED %s ON %s WITH %d EPISODES", a.MainTitle, match, count)
-
-		if count == 1 {
-			episode := &models.Episode{
-				AnimeID: a.ID,
-				From:    "dreamsub",
-				Number:  1,
-				Region:  models.RegionIT,
-				Title:   "",
-			}
-			d.getSource(match, a, episode)
-			episode.Save()
-		} else {
-			d.getEpisodes(match, a)
-		}
+	match, err := ModuleFuzzyWuzzy(ctx, &d, titles, a)
+	result := &Result{Confidence: match.Confidence, Match: match.URL}
+
+	if err != nil || match.URL == "" {
+		return result, err
+	}
+
+	log.Printf("[DREAMSUB] MATCHED %s ON %s WITH %d EPISODES (%.2f)", a.MainTitle, match.URL, match.Episodes, match.Confidence)
+
+	if match.Episodes != 1 {
+		return result, d.getEpisodes(ctx, match.URL, a, result)
+	}
+
+	episode := &models.Episode{
+		AnimeID: a.ID,
+		From:    "dreamsub",
+		Number:  1,
+		Region:  d.Settings.Region,
+		Title:   "",
 	}
+
+	err = d.getSource(ctx, match.URL, a, episode)
+
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	} else if err != nil {
+		result.AddError("episode 1 source not fetched: %s", err.Error())
+	}
+
+	result.SaveEpisode(episode)
+
+	return result, nil
 }
 
 // GetList retrieves search results list
-func (d Dreamsub) GetList(title string) *goquery.Selection {
-	query := "https://dreamsub.stream/search/?q=" + url.QueryEscape(title)
-	doc, err := ModuleScrapeURL(query)
+func (d Dreamsub) GetList(ctx context.Context, title string) (*goquery.Selection, error) {
+	query := d.Settings.BaseURL + "/search/?q=" + url.QueryEscape(title)
+	doc, err := ModuleScrapeURL(ctx, query, d.Settings.Proxy)
 
-	if err == nil {
-		return doc.Find("#main-content .goblock .tvBlock")
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return doc.Find("#main-content .goblock .tvBlock"), nil
 }
 
 // GetTarget retrieves search result title
@@ -77,7 +104,7 @@ func (d Dreamsub) AddToMatches(animeID int, episodes int, ratio float64, target
 		From:     "dreamsub",
 		Ratio:    ratio,
 		Title:    target,
-		URL:      "https://dreamsub.stream" + url,
+		URL:      d.Settings.BaseURL + url,
 	}
 }
 
@@ -92,11 +119,11 @@ func (d Dreamsub) GetMatches(animeID int) []models.Matching {
 	return matchings
 }
 
-func (d Dreamsub) getEpisodes(uri string, anime *models.Anime) {
-	doc, err := ModuleScrapeURL("https://dreamsub.stream" + uri)
+func (d Dreamsub) getEpisodes(ctx context.Context, uri string, anime *models.Anime, result *Result) error {
+	doc, err := ModuleScrapeURL(ctx, d.Settings.BaseURL+uri, d.Settings.Proxy)
 
 	if err != nil {
-		return
+		return err
 	}
 
 	doc.Find("#episodes-sv .ep-item").EachWithBreak(func(i int, s *goquery.Selection) bool {
@@ -122,30 +149,39 @@ func (d Dreamsub) getEpisodes(uri string, anime *models.Anime) {
 			AnimeID: anime.ID,
 			From:    "dreamsub",
 			Number:  1,
-			Region:  models.RegionIT,
+			Region:  d.Settings.Region,
 			Title:   "",
 		}
-		d.getSource(link, anime, episode)
+		err := d.getSource(ctx, link, anime, episode)
+
+		if ctx.Err() != nil {
+			return false
+		} else if err != nil {
+			result.AddError("episode %d source not fetched: %s", i+1, err.Error())
+		}
+
 		episode.Title = title
 		episode.Number = i + 1
 
-		episode.Save()
-
-		log.Printf("[DREAMSUB] SAVED EPISODE %d OF %s", i+1, anime.MainTitle)
+		if result.SaveEpisode(episode) == nil {
+			log.Printf("[DREAMSUB] SAVED EPISODE %d OF %s", i+1, anime.MainTitle)
+		}
 
 		return true
 	})
+
+	return ctx.Err()
 }
 
-func (d Dreamsub) getSource(uri string, anime *models.Anime, episode *models.Episode) {
+func (d Dreamsub) getSource(ctx context.Context, uri string, anime *models.Anime, episode *models.Episode) error {
 	if uri == "" {
-		return
+		return nil
 	}
 
-	doc, err := ModuleScrapeURL("https://dreamsub.stream" + uri)
+	doc, err := ModuleScrapeURL(ctx, d.Settings.BaseURL+uri, d.Settings.Proxy)
 
 	if err != nil {
-		return
+		return err
 	}
 
 	main := doc.Find("#main-content.onlyDesktop .goblock-content div")
@@ -173,7 +209,7 @@ func (d Dreamsub) getSource(uri string, anime *models.Anime, episode *models.Epi
 			src, _ := iframe.Attr("src")
 
 			if src != "" {
-				d.getSource(src, anime, episode)
+				err = d.getSource(ctx, src, anime, episode)
 			}
 		}
 	}
@@ -183,9 +219,17 @@ func (d Dreamsub) getSource(uri string, anime *models.Anime, episode *models.Epi
 	if vvvvid.Nodes != nil {
 		episode.Source, _ = vvvvid.Attr("href")
 	}
+
+	if episode.Source != "" {
+		return nil
+	}
+
+	return err
 }
 
 // NewDreamsub creates a new dreamsub module
-func NewDreamsub() Dreamsub {
-	return Dreamsub{}
+func NewDreamsub(settings models.ModuleSettings) Dreamsub {
+	return Dreamsub{
+		Settings: settings,
+	}
 }
@@ -2,21 +2,19 @@ package modules
 
 import (
 	"aniapi-go/models"
-	"encoding/json"
+	"context"
 	"log"
-	"math"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/gocolly/colly"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
 // Gogoanime is the https://gogoanime.pro/ module
-type Gogoanime struct{}
+type Gogoanime struct {
+	Settings models.ModuleSettings
+}
 
 // GogoanimeAjax is the https://gogoanime.pro/ajax response
 type GogoanimeAjax struct {
@@ -26,154 +24,189 @@ type GogoanimeAjax struct {
 	HTML   string `json:"html"`
 }
 
+// init registers the module disabled, the modules config file enables it
+func init() {
+	Register("gogoanime", models.ModuleSettings{
+		BaseURL: "https://gogoanime.pro",
+		Enabled: false,
+		Proxy:   true,
+		Region:  models.RegionEN,
+	}, func(settings models.ModuleSettings) (Module, error) {
+		return NewGogoanime(settings), nil
+	})
+}
+
 // Start the scraping flow
-func (g Gogoanime) Start(a *models.Anime, c *colly.Collector) {
-	match := g.findMatch(a, c)
+func (g Gogoanime) Start(ctx context.Context, a *models.Anime) (*Result, error) {
+	titles := append([]string{a.MainTitle}, a.AlternativesTitle...)
+	match, err := ModuleFuzzyWuzzy(ctx, &g, titles, a)
+	result := &Result{Confidence: match.Confidence, Match: match.URL}
 
-	if match != "" {
-		g.getEpisodes(match[len(match)-4:], a)
+	if err != nil || match.URL == "" {
+		return result, err
 	}
+
+	log.Printf("[GOGOANIME] MATCHED %s ON %s (%.2f)", a.MainTitle, match.URL, match.Confidence)
+
+	return result, g.getEpisodes(ctx, getGogoanimeFilmID(match.URL), a, result)
 }
 
-func (g Gogoanime) findMatch(a *models.Anime, c *colly.Collector) string {
-	titles := append([]string{a.MainTitle}, a.AlternativesTitle...)
-	match := ""
-	best := 99
-	ratio := 0.0
-
-	var otherMatches []*models.Matching
-
-	for _, title := range titles {
-		query := "https://gogoanime.pro/search/?language%5B%5D=subbed&keyword=" + url.QueryEscape(title)
-		//c := colly.NewCollector()
-
-		c.OnHTML("#wrapper .last_episodes .items", func(e *colly.HTMLElement) {
-			e.ForEach("li", func(_ int, el *colly.HTMLElement) {
-				target := strings.ToLower(el.DOM.Find(".name a").Text())
-				source := strings.Replace(strings.ToLower(title), ":", "", -1)
-
-				score := fuzzy.RankMatch(source, target)
-
-				bigger := math.Max(float64(len(source)), float64(len(target)))
-				r := (bigger - float64(score)) / bigger
-
-				if score < best && score != -1 && score <= 1 {
-					best = score
-					match, _ = el.DOM.Find(".img a").Attr("href")
-
-					ratio = r
-				}
-
-				if (score > 1 && len(source) > 2) || (len(source) <= 2 && score > 1 && score <= 10) {
-					url, _ := el.DOM.Find(".showStreaming a").Eq(0).Attr("href")
-					otherMatches = append(otherMatches, &models.Matching{
-						AnimeID:  a.ID,
-						Episodes: 0,
-						From:     "gogoanime",
-						Ratio:    r,
-						Title:    target,
-						URL:      "https://gogoanime.pro" + url,
-					})
-				}
-			})
-		})
-
-		c.Visit(query)
-
-		if match == "" {
-			for _, m := range otherMatches {
-				m.Save()
-			}
-		}
+// GetList retrieves search results list
+func (g Gogoanime) GetList(ctx context.Context, title string) (*goquery.Selection, error) {
+	query := g.Settings.BaseURL + "/search/?language%5B%5D=subbed&keyword=" + url.QueryEscape(title)
+	doc, err := ModuleScrapeURL(ctx, query, g.Settings.Proxy)
+
+	if err != nil {
+		return nil, err
 	}
 
-	if match != "" {
-		log.Printf("[GOGOANIME] MATCHED %s ON %s WITH %f RATIO", a.MainTitle, match, ratio)
-	} else {
-		matches, err := models.FindMatchings(a.ID, "gogoanime", "votes", true)
+	return doc.Find("#wrapper .last_episodes .items li"), nil
+}
 
-		if err == nil && len(matches) > 0 {
-			if matches[0].Votes > 0 {
-				match = "/" + strings.Join(strings.Split(matches[0].URL, "/")[3:5], "/")
-				log.Printf("[GOGOANIME] VOTE MATCHED %s ON %s WITH %d VOTES", a.MainTitle, match, matches[0].Votes)
-			}
-		}
-	}
+// GetTarget retrieves search result title
+func (g Gogoanime) GetTarget(s *goquery.Selection) string {
+	return strings.ToLower(strings.TrimSpace(s.Find(".name a").Text()))
+}
 
-	return match
+// GetEpisodesNumber retrieves search result episodes number
+// Search results do not show it
+func (g Gogoanime) GetEpisodesNumber(s *goquery.Selection) int {
+	return 0
 }
 
-func (g Gogoanime) getEpisodes(id string, anime *models.Anime) {
-	var episodes []string
-	response := &GogoanimeAjax{}
+// GetYear retrieves search result release year
+func (g Gogoanime) GetYear(s *goquery.Selection) int {
+	year, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s.Find(".released").Text()), "Released:")))
+	return year
+}
+
+// GetType retrieves search result anime type
+// Search results do not show it
+func (g Gogoanime) GetType(s *goquery.Selection) string {
+	return ""
+}
+
+// GetURL retrieves search result anime url
+func (g Gogoanime) GetURL(s *goquery.Selection) string {
+	url, _ := s.Find(".name a").Attr("href")
+	return url
+}
 
-	uri := "https://gogoanime.pro/ajax/film/servers/" + id + "?ep=&episode="
+// AddToMatches adds a search result to possible matchings
+func (g Gogoanime) AddToMatches(animeID int, episodes int, ratio float64, target string, url string) *models.Matching {
+	return &models.Matching{
+		AnimeID:  animeID,
+		Episodes: episodes,
+		From:     "gogoanime",
+		Ratio:    ratio,
+		Title:    target,
+		URL:      g.Settings.BaseURL + url,
+	}
+}
 
-	res, err := http.Get(uri)
+// GetMatches retrieves an anime model possible matchings
+func (g Gogoanime) GetMatches(animeID int) []models.Matching {
+	matchings, err := models.FindMatchings(animeID, "gogoanime", "votes", true)
 
 	if err != nil {
-		return
+		return nil
 	}
 
-	defer res.Body.Close()
+	return matchings
+}
+
+func (g Gogoanime) getEpisodes(ctx context.Context, id string, anime *models.Anime, result *Result) error {
+	response := &GogoanimeAjax{}
 
-	err = json.NewDecoder(res.Body).Decode(response)
+	err := ModuleGetJSON(ctx, g.Settings.BaseURL+"/ajax/film/servers/"+id+"?ep=&episode=", g.Settings.Proxy, response)
 
 	if err != nil {
-		return
+		return err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(response.HTML))
 
+	if err != nil {
+		log.Printf("[GOGOANIME] EPISODES OF %s NOT PARSED: %s", anime.MainTitle, err.Error())
+		return err
+	}
+
+	var episodes []string
+
 	doc.Find("#episodes ul li").Each(func(_ int, s *goquery.Selection) {
 		name, _ := s.Find("a").Attr("data-name")
+
 		if name != "" {
 			episodes = append(episodes, name)
 		}
 	})
 
 	for _, ep := range episodes {
-		ok := false
+		number, err := strconv.Atoi(strings.Split(ep, ":")[0])
 
-		for ok == false {
-			response = &GogoanimeAjax{}
-			ok = false
+		if err != nil {
+			continue
+		}
 
-			number, _ := strconv.Atoi(strings.Split(ep, ":")[0])
-			uri = "https://gogoanime.pro/ajax/episode/info?filmId=" + id + "&server=40&episode=" + ep + "&mcloud=9568c"
+		source := g.getSource(ctx, id, ep)
 
-			res, err = http.Get(uri)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 
-			if err != nil {
-				continue
-			}
+		if source == nil {
+			log.Printf("[GOGOANIME] EPISODE %d OF %s SKIPPED, SOURCE NOT FOUND", number, anime.MainTitle)
+			result.AddError("episode %d source not found", number)
+			continue
+		}
 
-			defer res.Body.Close()
+		episode := &models.Episode{
+			AnimeID: anime.ID,
+			From:    "gogoanime",
+			Number:  number,
+			Region:  g.Settings.Region,
+			Source:  source.Target,
+			Title:   source.Name,
+		}
 
-			err = json.NewDecoder(res.Body).Decode(response)
+		if result.SaveEpisode(episode) == nil {
+			log.Printf("[GOGOANIME] SAVED EPISODE %d OF %s", number, anime.MainTitle)
+		}
+	}
+
+	return nil
+}
 
-			if err != nil {
-				continue
-			}
+// getSource asks the source of an episode, nil when it is not returned
+// Request failures are retried by the shared fetcher, within the host budget
+func (g Gogoanime) getSource(ctx context.Context, id string, ep string) *GogoanimeAjax {
+	uri := g.Settings.BaseURL + "/ajax/episode/info?filmId=" + id + "&server=40&episode=" + url.QueryEscape(ep) + "&mcloud=9568c"
+	response := &GogoanimeAjax{}
 
-			episode := &models.Episode{
-				AnimeID: anime.ID,
-				From:    "gogoanime",
-				Number:  number,
-				Region:  models.RegionEN,
-				Source:  response.Target,
-				Title:   response.Name,
-			}
+	if ModuleGetJSON(ctx, uri, g.Settings.Proxy, response) != nil || response.Target == "" {
+		return nil
+	}
 
-			if episode.Source != "" {
-				episode.Save()
-				ok = true
-			}
-		}
+	return response
+}
+
+// getGogoanimeFilmID returns the film id of an anime url, like
+// 8wpm for /anime/cowboy-bebop.8wpm
+func getGogoanimeFilmID(uri string) string {
+	if i := strings.LastIndex(uri, "."); i != -1 && i > strings.LastIndex(uri, "/") {
+		return uri[i+1:]
+	}
+
+	if len(uri) < 4 {
+		return uri
 	}
+
+	return uri[len(uri)-4:]
 }
 
 // NewGogoanime creates a new gogoanime module
-func NewGogoanime() Gogoanime {
-	return Gogoanime{}
+func NewGogoanime(settings models.ModuleSettings) Gogoanime {
+	return Gogoanime{
+		Settings: settings,
+	}
 }
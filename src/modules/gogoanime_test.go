@@ -0,0 +1,76 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"aniapi-go/utils"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGogoanimeEpisodes runs the module on Cowboy Bebop using the fixtures
+// and checks every episode has been saved with its source
+func TestGogoanimeEpisodes(t *testing.T) {
+	models.SetStore(models.NewMemoryStore())
+	transport := utils.UseFixtures("../fixtures", utils.FixtureReplay)
+
+	anime := &models.Anime{
+		AiringStart:          time.Date(1998, time.April, 3, 0, 0, 0, 0, time.UTC),
+		AiringStartPrecision: utils.DatePrecisionDay,
+		AlternativesTitle:    []string{"Cowboy Bebop"},
+		Episodes:             26,
+		MainTitle:            "Cowboy Bebop",
+		Status:               models.AnimeStatusFinished,
+	}
+
+	if err := anime.Save(); err != nil {
+		t.Fatalf("anime not saved: %s", err.Error())
+	}
+
+	module := NewGogoanime(models.ModuleSettings{
+		BaseURL: "https://gogoanime.pro",
+		Proxy:   true,
+		Region:  models.RegionEN,
+	})
+
+	result, err := module.Start(context.Background(), anime)
+
+	if missing := transport.Missing(); len(missing) > 0 {
+		t.Fatalf("fixtures not found for:\n%s", strings.Join(missing, "\n"))
+	}
+
+	if err != nil {
+		t.Fatalf("module failed: %s", err.Error())
+	}
+
+	if len(result.Errors) > 0 {
+		t.Fatalf("module errors: %s", strings.Join(result.Errors, ", "))
+	}
+
+	expected := []string{
+		"https://vidstream.pro/embed/cb01",
+		"https://vidstream.pro/embed/cb02",
+		"https://vidstream.pro/embed/cb03",
+	}
+
+	if result.Episodes != len(expected) || result.New != len(expected) {
+		t.Fatalf("expected %d new episodes, got %d found and %d new", len(expected), result.Episodes, result.New)
+	}
+
+	episodes, err := models.FindEpisodes(anime.ID, 0, "gogoanime", "", utils.GetPageInfo(1), "number", false)
+
+	if err != nil {
+		t.Fatalf("episodes not found: %s", err.Error())
+	}
+
+	if len(episodes) != len(expected) {
+		t.Fatalf("expected %d episodes, got %d", len(expected), len(episodes))
+	}
+
+	for i, e := range episodes {
+		if e.Number != i+1 || e.Source != expected[i] {
+			t.Errorf("episode %d: expected %s, got %d %s", i+1, expected[i], e.Number, e.Source)
+		}
+	}
+}
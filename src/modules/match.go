@@ -0,0 +1,288 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lithammer/fuzzysearch/fuzzy"
+)
+
+// DetailedModule is a module whose search results show the year and the type
+// of the anime, used to score the search results
+// Unknown values are returned as 0 and as an empty string
+type DetailedModule interface {
+	GetYear(s *goquery.Selection) int
+	GetType(s *goquery.Selection) string
+}
+
+// Match is the search result chosen for an anime, with the confidence of
+// the choice between 0 and 1
+type Match struct {
+	Confidence float64
+	Episodes   int
+	URL        string
+}
+
+// Title is a normalized anime title, split into comparable tokens
+// The season number is taken out of the tokens, 1 when not stated
+type Title struct {
+	Season int
+	Tokens []string
+}
+
+// MatchThreshold is the minimum score of a search result to be chosen
+var MatchThreshold = 0.9
+
+// CandidateThreshold is the minimum score of a search result to be saved as
+// a possible matching, to be voted by the users
+var CandidateThreshold = 0.5
+
+// matchWeights are the weights of the score components: title, episodes
+// count, year and type
+// Components unknown on either side are left out of the score
+var matchWeights = [4]float64{0.7, 0.1, 0.1, 0.1}
+
+var titleTagRegex = regexp.MustCompile(`[(\[]\s*(tv|ova|ona|oav|movie|special|specials|uncensored|(19|20)\d\d)\s*[)\]]`)
+var titleSymbolRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+var titleAccents = strings.NewReplacer(
+	"ā", "a", "â", "a", "à", "a", "á", "a", "ä", "a",
+	"ē", "e", "ê", "e", "è", "e", "é", "e", "ë", "e",
+	"ī", "i", "î", "i", "ì", "i", "í", "i", "ï", "i",
+	"ō", "o", "ô", "o", "ò", "o", "ó", "o", "ö", "o",
+	"ū", "u", "û", "u", "ù", "u", "ú", "u", "ü", "u",
+	"&", " and ", "'", "", "’", "", "`", "",
+)
+
+var titleRomanization = strings.NewReplacer("ou", "o", "oo", "o", "uu", "u", "ei", "e")
+
+// titleRomanNumerals leaves out v and x, which are often part of the title
+var titleRomanNumerals = map[string]int{
+	"ii": 2, "iii": 3, "iv": 4, "vi": 6, "vii": 7, "viii": 8, "ix": 9,
+}
+
+var titleNumberWords = map[string]int{
+	"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6,
+}
+
+var titleOrdinalRegex = regexp.MustCompile(`^(\d+)(st|nd|rd|th)$`)
+var titleShortSeasonRegex = regexp.MustCompile(`^s(\d+)$`)
+
+// NormalizeTitle turns a title into comparable tokens:
+// - lowercase, without accents, punctuation and tags like (TV) or (2011)
+// - roman numerals and ordinals as numbers
+// - "Season 2", "2nd Season", "S2" and a final "2" as season number
+// - long vowels written the same way, so Shōnen, Shounen and Shonen match
+func NormalizeTitle(title string) Title {
+	title = titleAccents.Replace(strings.ToLower(title))
+	title = titleTagRegex.ReplaceAllString(title, " ")
+	title = titleSymbolRegex.ReplaceAllString(title, " ")
+
+	words := strings.Fields(title)
+	t := Title{Season: 1}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		if word == "the" {
+			continue
+		}
+
+		if word == "season" && i+1 < len(words) {
+			if n, ok := titleNumber(words[i+1], true); ok {
+				t.Season = n
+				i++
+				continue
+			}
+		}
+
+		if i+1 < len(words) && words[i+1] == "season" && (i+2 == len(words) || !isTitleNumber(words[i+2])) {
+			if n, ok := titleNumber(word, true); ok {
+				t.Season = n
+				i++
+				continue
+			}
+		}
+
+		if m := titleShortSeasonRegex.FindStringSubmatch(word); m != nil {
+			t.Season, _ = strconv.Atoi(m[1])
+			continue
+		}
+
+		if n, ok := titleNumber(word, false); ok {
+			if i == len(words)-1 && i > 0 && n > 1 && n < 10 {
+				t.Season = n
+				continue
+			}
+
+			word = strconv.Itoa(n)
+		}
+
+		if word == "wo" {
+			word = "o"
+		}
+
+		t.Tokens = append(t.Tokens, titleRomanization.Replace(word))
+	}
+
+	return t
+}
+
+// String returns the normalized title, with its season when not the first
+func (t Title) String() string {
+	s := strings.Join(t.Tokens, " ")
+
+	if t.Season > 1 {
+		s += " s" + strconv.Itoa(t.Season)
+	}
+
+	return s
+}
+
+// Similarity compares two normalized titles, from 0 to 1
+// Tokens are paired with their most similar token, so that a typo does not
+// fail the whole match; titles of different seasons are halved
+func (t Title) Similarity(o Title) float64 {
+	if len(t.Tokens) == 0 || len(o.Tokens) == 0 {
+		return 0
+	}
+
+	used := make([]bool, len(o.Tokens))
+	total := 0.0
+
+	for _, a := range t.Tokens {
+		best, index := 0.0, -1
+
+		for j, b := range o.Tokens {
+			if used[j] {
+				continue
+			}
+
+			if s := tokenSimilarity(a, b); s > best {
+				best, index = s, j
+			}
+		}
+
+		if index != -1 {
+			used[index] = true
+			total += best
+		}
+	}
+
+	similarity := 2 * total / float64(len(t.Tokens)+len(o.Tokens))
+
+	if t.Season != o.Season {
+		similarity /= 2
+	}
+
+	return similarity
+}
+
+// ScoreMatch scores a search result of an anime, from 0 to 1, combining the
+// titles similarity with the agreement of the episodes count, the year and
+// the type when the search result shows them
+// Fewer episodes than the anime ones agree, as sources often upload them
+// partially; only more episodes lower the score
+func ScoreMatch(a *models.Anime, similarity float64, episodes int, year int, kind string) float64 {
+	scores := [4]float64{similarity, -1, -1, -1}
+
+	if a.Episodes > 0 && episodes > 0 {
+		if episodes <= a.Episodes {
+			scores[1] = 1
+		} else {
+			scores[1] = math.Min(float64(a.Episodes), float64(episodes)) / math.Max(float64(a.Episodes), float64(episodes))
+		}
+	}
+
+	animeYear := a.SeasonYear
+
+	if animeYear == 0 && !a.AiringStart.IsZero() {
+		animeYear = a.AiringStart.Year()
+	}
+
+	if animeYear > 0 && year > 0 {
+		switch diff := animeYear - year; {
+		case diff == 0:
+			scores[2] = 1
+		case diff == 1 || diff == -1:
+			scores[2] = 0.5
+		default:
+			scores[2] = 0
+		}
+	}
+
+	if a.Type != "" && kind != "" {
+		scores[3] = 0
+
+		if strings.EqualFold(strings.TrimSpace(a.Type), strings.TrimSpace(kind)) {
+			scores[3] = 1
+		}
+	}
+
+	score, weights := 0.0, 0.0
+
+	for i, s := range scores {
+		if s >= 0 {
+			score += s * matchWeights[i]
+			weights += matchWeights[i]
+		}
+	}
+
+	return score / weights
+}
+
+// titleNumber reads a number, a roman numeral or an ordinal
+// Number words like "second" are read only within a season
+func titleNumber(word string, season bool) (int, bool) {
+	if m := titleOrdinalRegex.FindStringSubmatch(word); m != nil {
+		word = m[1]
+	}
+
+	if n, err := strconv.Atoi(word); err == nil {
+		return n, true
+	}
+
+	if n, ok := titleRomanNumerals[word]; ok {
+		return n, true
+	}
+
+	if n, ok := titleNumberWords[word]; ok && season {
+		return n, true
+	}
+
+	return 0, false
+}
+
+func isTitleNumber(word string) bool {
+	_, ok := titleNumber(word, true)
+	return ok
+}
+
+// tokenSimilarity compares two tokens, from 0 to 1
+// Numbers must be equal, words differing by a letter every five are similar
+func tokenSimilarity(a string, b string) float64 {
+	if a == b {
+		return 1
+	}
+
+	if _, err := strconv.Atoi(a); err == nil {
+		return 0
+	}
+
+	if _, err := strconv.Atoi(b); err == nil {
+		return 0
+	}
+
+	longer := math.Max(float64(len(a)), float64(len(b)))
+	s := 1 - float64(fuzzy.LevenshteinDistance(a, b))/longer
+
+	if s < 0.8 {
+		return 0
+	}
+
+	return s
+}
@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	cases := map[string]string{
+		"Shingeki no Kyojin Season 2":   "shingeki no kyojin s2",
+		"Shingeki no Kyojin 2nd Season": "shingeki no kyojin s2",
+		"Shingeki no Kyojin S2":         "shingeki no kyojin s2",
+		"Shōnen Onmyōji (TV)":           "shonen onmyoji",
+		"Shounen Onmyouji":              "shonen onmyoji",
+		"Overlord III":                  "overlord s3",
+	}
+
+	for title, expected := range cases {
+		if got := NormalizeTitle(title).String(); got != expected {
+			t.Errorf("%s: expected %q, got %q", title, expected, got)
+		}
+	}
+}
+
+// TestScoreMatchPartialUpload checks that a source having uploaded only some
+// episodes of a finished anime is still chosen
+func TestScoreMatchPartialUpload(t *testing.T) {
+	anime := &models.Anime{
+		Episodes:   26,
+		MainTitle:  "Cowboy Bebop",
+		SeasonYear: 1998,
+		Status:     models.AnimeStatusFinished,
+	}
+
+	similarity := NormalizeTitle(anime.MainTitle).Similarity(NormalizeTitle("Cowboy Bebop"))
+
+	if score := ScoreMatch(anime, similarity, 2, 1998, ""); score < MatchThreshold {
+		t.Errorf("partial upload scored %.2f, below %.2f", score, MatchThreshold)
+	}
+
+	if score := ScoreMatch(anime, similarity, 52, 1998, ""); score >= ScoreMatch(anime, similarity, 26, 1998, "") {
+		t.Errorf("more episodes than the anime ones scored %.2f, not lower", score)
+	}
+}
@@ -0,0 +1,354 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Factory creates a module from its settings
+// It returns an error when the module can not work with the settings
+type Factory func(settings models.ModuleSettings) (Module, error)
+
+// ModuleStatus is the status of a registered module, with the statistics of
+// its runs since startup
+type ModuleStatus struct {
+	Errors   int                   `json:"errors"`
+	LastRun  *models.ModuleResult  `json:"last_run"`
+	Name     string                `json:"name"`
+	Running  int                   `json:"running"`
+	Runs     int                   `json:"runs"`
+	Settings models.ModuleSettings `json:"settings"`
+}
+
+// registration is a registered module, where defaults are its default
+// settings changed by the modules config file, and stored the settings
+// changed at runtime
+type registration struct {
+	defaults models.ModuleSettings
+	factory  Factory
+	module   Module
+	slots    chan bool
+	status   ModuleStatus
+	stored   models.ModuleSettingsPatch
+}
+
+// DefaultConcurrency is the number of animes a module works on at the same
+// time, unless configured otherwise
+var DefaultConcurrency = 2
+
+// ErrUnknownModule is returned when no module is registered with a name
+var ErrUnknownModule = errors.New("unknown module")
+
+// ErrDuplicateModule is returned when a module is registered twice
+var ErrDuplicateModule = errors.New("module already registered")
+
+// ErrInvalidSettings is returned when module settings are not valid
+var ErrInvalidSettings = errors.New("invalid module settings")
+
+var registry = make(map[string]*registration)
+var registryNames []string
+var registryMutex sync.RWMutex
+
+// Register adds a module to the registry with its default settings
+// Should be called from the init function of the module file
+// It returns ErrInvalidSettings when the module can not be created with
+// its default settings
+func Register(name string, defaults models.ModuleSettings, factory Factory) error {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := registry[name]; ok {
+		return ErrDuplicateModule
+	}
+
+	defaults.Name = name
+
+	if defaults.Concurrency == 0 {
+		defaults.Concurrency = DefaultConcurrency
+	}
+
+	if !defaults.IsValid() {
+		return ErrInvalidSettings
+	}
+
+	r := &registration{
+		defaults: defaults,
+		factory:  factory,
+	}
+
+	if err := r.configure(defaults); err != nil {
+		return fmt.Errorf("%s: %s", ErrInvalidSettings.Error(), err.Error())
+	}
+
+	registry[name] = r
+	registryNames = append(registryNames, name)
+
+	return nil
+}
+
+// Init loads the declarative modules, the modules config file and the
+// settings changed at runtime
+// Should be called once at startup, after the store is set up
+func Init(configPath string, definitionsDir string) {
+	RegisterDefinitions(definitionsDir)
+
+	err := loadConfig(configPath)
+
+	if err != nil {
+		log.Printf("MODULES CONFIG %s NOT LOADED: %s", configPath, err.Error())
+	}
+
+	settings, err := models.FindModuleSettings()
+
+	if err != nil {
+		log.Printf("MODULE SETTINGS NOT LOADED: %s", err.Error())
+		return
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for _, patch := range settings {
+		r, ok := registry[patch.Name]
+
+		if !ok {
+			log.Printf("MODULE %s SETTINGS IGNORED, MODULE NOT REGISTERED", patch.Name)
+			continue
+		}
+
+		s := r.defaults
+		patch.Apply(&s)
+
+		if !s.IsValid() || r.configure(s) != nil {
+			log.Printf("MODULE %s SETTINGS IGNORED, SETTINGS NOT VALID", patch.Name)
+			continue
+		}
+
+		r.stored = patch
+	}
+}
+
+// loadConfig reads the modules config file, a map of settings changes by
+// module name; a missing file means no change
+func loadConfig(path string) error {
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	config := make(map[string]models.ModuleSettingsPatch)
+	err = yaml.UnmarshalStrict(data, &config)
+
+	if err != nil {
+		return err
+	}
+
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for name, patch := range config {
+		r, ok := registry[name]
+
+		if !ok {
+			log.Printf("MODULES CONFIG %s IGNORED, MODULE NOT REGISTERED", name)
+			continue
+		}
+
+		settings := r.defaults
+		patch.Apply(&settings)
+
+		if !settings.IsValid() || r.configure(settings) != nil {
+			log.Printf("MODULES CONFIG %s IGNORED, SETTINGS NOT VALID", name)
+			continue
+		}
+
+		r.defaults = settings
+	}
+
+	return nil
+}
+
+// Configure changes the settings of a module and stores the changed fields
+// Fields never changed at runtime keep following the modules config file
+func Configure(name string, patch models.ModuleSettingsPatch) (ModuleStatus, error) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	r, ok := registry[name]
+
+	if !ok {
+		return ModuleStatus{}, ErrUnknownModule
+	}
+
+	stored := r.stored.Merge(patch)
+	stored.Name = name
+
+	previous := r.status.Settings
+	settings := r.defaults
+	stored.Apply(&settings)
+
+	if !settings.IsValid() || r.configure(settings) != nil {
+		return r.status, ErrInvalidSettings
+	}
+
+	err := stored.Save()
+
+	if err != nil {
+		r.configure(previous)
+		return r.status, err
+	}
+
+	r.stored = stored
+
+	return r.status, nil
+}
+
+// Reset removes the stored settings of a module, restoring the defaults
+// and the modules config file ones
+func Reset(name string) (ModuleStatus, error) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	r, ok := registry[name]
+
+	if !ok {
+		return ModuleStatus{}, ErrUnknownModule
+	}
+
+	err := models.ResetModuleSettings(name)
+
+	if err != nil {
+		return r.status, err
+	}
+
+	r.stored = models.ModuleSettingsPatch{}
+	r.configure(r.defaults)
+
+	return r.status, nil
+}
+
+// GetStatus returns the status of a registered module
+func GetStatus(name string) (ModuleStatus, error) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	r, ok := registry[name]
+
+	if !ok {
+		return ModuleStatus{}, ErrUnknownModule
+	}
+
+	return r.status, nil
+}
+
+// GetStatuses returns the status of every registered module
+func GetStatuses() []ModuleStatus {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	statuses := make([]ModuleStatus, 0, len(registryNames))
+
+	for _, name := range registryNames {
+		statuses = append(statuses, registry[name].status)
+	}
+
+	return statuses
+}
+
+// Enabled returns the names of the enabled modules
+func Enabled() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	var names []string
+
+	for _, name := range registryNames {
+		if registry[name].status.Settings.Enabled {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Run runs an enabled module, waiting for one of its concurrency slots,
+// and records the result into the module statistics
+// When the context is done before a slot is free, the module does not run
+// and the result holds the context error
+// It returns false when the module is not registered or not enabled
+func Run(ctx context.Context, name string, run func(m Module) models.ModuleResult) (models.ModuleResult, bool) {
+	registryMutex.Lock()
+	r, ok := registry[name]
+
+	if !ok || !r.status.Settings.Enabled {
+		registryMutex.Unlock()
+		return models.ModuleResult{}, false
+	}
+
+	module, slots := r.module, r.slots
+	r.status.Running++
+	registryMutex.Unlock()
+
+	select {
+	case slots <- true:
+	case <-ctx.Done():
+		registryMutex.Lock()
+		r.status.Running--
+		registryMutex.Unlock()
+
+		now := time.Now()
+
+		return models.ModuleResult{
+			EndDate:   now,
+			Error:     ctx.Err().Error(),
+			Module:    name,
+			StartDate: now,
+		}, true
+	}
+
+	result := run(module)
+	<-slots
+
+	registryMutex.Lock()
+	r.status.Running--
+	r.status.Runs++
+
+	if result.Error != "" {
+		r.status.Errors++
+	}
+
+	r.status.LastRun = &result
+	registryMutex.Unlock()
+
+	return result, true
+}
+
+// configure creates the module instance for new settings, keeping the
+// current one when the factory fails
+// Runs already started keep their instance and concurrency slots
+func (r *registration) configure(settings models.ModuleSettings) error {
+	module, err := r.factory(settings)
+
+	if err != nil {
+		return err
+	}
+
+	r.status.Name = r.defaults.Name
+	r.status.Settings = settings
+	r.module = module
+	r.slots = make(chan bool, settings.Concurrency)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package modules
+
+import (
+	"aniapi-go/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConfigureStoresChangedFields checks that the stored settings hold the
+// changed fields only, so that the config file still sets the other ones
+func TestConfigureStoresChangedFields(t *testing.T) {
+	models.SetStore(models.NewMemoryStore())
+
+	err := Register("registry-test", models.ModuleSettings{
+		BaseURL: "https://example.com",
+		Enabled: false,
+		Region:  models.RegionEN,
+	}, func(settings models.ModuleSettings) (Module, error) {
+		return NewGogoanime(settings), nil
+	})
+
+	if err != nil {
+		t.Fatalf("module not registered: %s", err.Error())
+	}
+
+	concurrency := 4
+
+	if _, err := Configure("registry-test", models.ModuleSettingsPatch{Concurrency: &concurrency}); err != nil {
+		t.Fatalf("module not configured: %s", err.Error())
+	}
+
+	stored, err := models.FindModuleSettings()
+
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("expected 1 stored settings, got %d (%v)", len(stored), err)
+	}
+
+	if stored[0].Proxy != nil || stored[0].BaseURL != nil || stored[0].Concurrency == nil {
+		t.Errorf("expected only the concurrency to be stored, got %+v", stored[0])
+	}
+
+	dir, err := ioutil.TempDir("", "modules")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "modules.yml")
+	err = ioutil.WriteFile(config, []byte("registry-test:\n  proxy: true\n  concurrency: 1\n"), 0644)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	Init(config, filepath.Join(dir, "modules.d"))
+
+	status, err := GetStatus("registry-test")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !status.Settings.Proxy || status.Settings.Concurrency != concurrency {
+		t.Errorf("expected the config proxy and the stored concurrency, got %+v", status.Settings)
+	}
+
+	tooMany := models.ModuleMaxConcurrency + 1
+
+	if _, err := Configure("registry-test", models.ModuleSettingsPatch{Concurrency: &tooMany}); err != ErrInvalidSettings {
+		t.Errorf("expected %v for concurrency %d, got %v", ErrInvalidSettings, tooMany, err)
+	}
+}
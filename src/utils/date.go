@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DatePrecision is the part of a partial date actually known
+type DatePrecision string
+
+const (
+	// DatePrecisionUnknown means the date is not known at all
+	DatePrecisionUnknown DatePrecision = "unknown"
+	// DatePrecisionYear means only the year of the date is known
+	DatePrecisionYear DatePrecision = "year"
+	// DatePrecisionMonth means only the year and the month of the date are known
+	DatePrecisionMonth DatePrecision = "month"
+	// DatePrecisionDay means the whole date is known
+	DatePrecisionDay DatePrecision = "day"
+)
+
+var partialDateLayouts = []struct {
+	Layout    string
+	Precision DatePrecision
+}{
+	{"Jan 2, 2006", DatePrecisionDay},
+	{"Jan 2 2006", DatePrecisionDay},
+	{"Jan, 2006", DatePrecisionMonth},
+	{"Jan 2006", DatePrecisionMonth},
+	{"2006", DatePrecisionYear},
+}
+
+// ParsePartialDate parses dates like "Apr 3, 1998", "Apr 2010" or "2010"
+// Missing parts are set to their first value, "?" and empty values return
+// an unknown precision without error
+func ParsePartialDate(s string) (time.Time, DatePrecision, error) {
+	s = strings.Join(strings.Fields(s), " ")
+
+	if s == "" || s == "?" || strings.EqualFold(s, "Not available") {
+		return time.Time{}, DatePrecisionUnknown, nil
+	}
+
+	for _, l := range partialDateLayouts {
+		date, err := time.Parse(l.Layout, s)
+
+		if err == nil {
+			return date, l.Precision, nil
+		}
+	}
+
+	return time.Time{}, DatePrecisionUnknown, fmt.Errorf("unrecognized date %q", s)
+}
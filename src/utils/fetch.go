@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FetchErrorKind is the enumerator type of fetch errors
+type FetchErrorKind string
+
+const (
+	// FetchRateLimited mean the host answered 429 Too Many Requests
+	FetchRateLimited FetchErrorKind = "rate_limited"
+	// FetchNotFound mean the resource does not exist
+	FetchNotFound FetchErrorKind = "not_found"
+	// FetchBlocked mean the host refused the request
+	FetchBlocked FetchErrorKind = "blocked"
+	// FetchServerError mean the host failed to answer
+	FetchServerError FetchErrorKind = "server_error"
+	// FetchRetryLater mean the host asked to repeat the request later, with
+	// 408 Request Timeout or 425 Too Early
+	FetchRetryLater FetchErrorKind = "retry_later"
+	// FetchUnexpectedStatus mean the host answered with any other status
+	FetchUnexpectedStatus FetchErrorKind = "unexpected_status"
+	// FetchNetworkError mean the request did not reach the host
+	FetchNetworkError FetchErrorKind = "network_error"
+	// FetchParseFailure mean the response body could not be parsed
+	FetchParseFailure FetchErrorKind = "parse_failure"
+)
+
+// FetchError is the error returned by a failed fetch
+type FetchError struct {
+	Kind       FetchErrorKind
+	URL        string
+	Status     int
+	Err        error
+	retryAfter time.Duration
+}
+
+func (e *FetchError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s fetching %s: %s", e.Kind, e.URL, e.Err.Error())
+	}
+
+	return fmt.Sprintf("%s fetching %s: status %d", e.Kind, e.URL, e.Status)
+}
+
+// Temporary checks if the failed fetch may succeed when retried
+// A blocked request is refused on purpose, so it is not retried
+func (e *FetchError) Temporary() bool {
+	switch e.Kind {
+	case FetchNotFound, FetchParseFailure, FetchBlocked, FetchUnexpectedStatus:
+		return false
+	}
+
+	return true
+}
+
+// IsFetchError checks if an error is a fetch error of the given kind
+func IsFetchError(err error, kind FetchErrorKind) bool {
+	var fe *FetchError
+
+	if errors.As(err, &fe) {
+		return fe.Kind == kind
+	}
+
+	return false
+}
+
+// Fetcher performs HTTP requests through proxies, respecting per host rate
+// limits and retrying temporary failures with exponential backoff
+type Fetcher struct {
+	Client        *http.Client
+	MaxRetries    int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	MaxRetryAfter time.Duration
+	Unlimited     bool
+}
+
+// DefaultFetcher is the fetcher shared by the scraper and the modules
+var DefaultFetcher = NewFetcher()
+
+// DirectFetcher is the fetcher used by the modules not using proxies
+var DirectFetcher = NewDirectFetcher()
+
+// Do sends a request, retrying it on temporary failures
+// Requests with a body must be created with a GetBody function, as
+// http.NewRequest does for in memory readers
+// Retries and rate limit waits stop when the request context is done
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	var lastErr *FetchError
+
+	for attempt := 0; attempt <= f.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := f.backoff(attempt, lastErr)
+			log.Printf("URL (%s) RETRY %d IN %s: %s", req.URL, attempt, delay, lastErr.Error())
+
+			select {
+			case <-time.After(delay):
+			case <-req.Context().Done():
+				return nil, &FetchError{Kind: FetchNetworkError, URL: req.URL.String(), Err: req.Context().Err()}
+			}
+
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+
+				if err != nil {
+					return nil, err
+				}
+
+				req.Body = body
+			}
+		}
+
+		if !f.Unlimited {
+			if err := WaitForHost(req.Context(), req.URL.Host); err != nil {
+				return nil, &FetchError{Kind: FetchNetworkError, URL: req.URL.String(), Err: err}
+			}
+		}
+
+		resp, err := f.Client.Do(req)
+
+		if err != nil {
+			lastErr = &FetchError{Kind: FetchNetworkError, URL: req.URL.String(), Err: err}
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		lastErr = classifyResponse(req, resp)
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if !lastErr.Temporary() {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// Get fetches an url
+func (f *Fetcher) Get(url string) (*http.Response, error) {
+	return f.GetContext(context.Background(), url)
+}
+
+// GetContext fetches an url, giving up when the context is done
+func (f *Fetcher) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+
+	if err != nil {
+		return nil, &FetchError{Kind: FetchNetworkError, URL: url, Err: err}
+	}
+
+	return f.Do(req)
+}
+
+// GetDocument fetches an url and parses its HTML
+func (f *Fetcher) GetDocument(url string) (*goquery.Document, error) {
+	return f.GetDocumentContext(context.Background(), url)
+}
+
+// GetDocumentContext fetches an url and parses its HTML, giving up when the
+// context is done
+func (f *Fetcher) GetDocumentContext(ctx context.Context, url string) (*goquery.Document, error) {
+	resp, err := f.GetContext(ctx, url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+
+	if err != nil {
+		return nil, &FetchError{Kind: FetchParseFailure, URL: url, Status: resp.StatusCode, Err: err}
+	}
+
+	return doc, nil
+}
+
+func (f *Fetcher) backoff(attempt int, lastErr *FetchError) time.Duration {
+	delay := f.BaseDelay * time.Duration(1<<uint(attempt-1))
+
+	if delay > f.MaxDelay {
+		delay = f.MaxDelay
+	}
+
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if lastErr != nil && lastErr.retryAfter > delay {
+		delay = lastErr.retryAfter
+
+		if delay > f.MaxRetryAfter {
+			delay = f.MaxRetryAfter
+		}
+	}
+
+	return delay
+}
+
+func classifyResponse(req *http.Request, resp *http.Response) *FetchError {
+	err := &FetchError{
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err.Kind = FetchRateLimited
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		err.Kind = FetchNotFound
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		err.Kind = FetchBlocked
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooEarly:
+		err.Kind = FetchRetryLater
+	case resp.StatusCode >= 500:
+		err.Kind = FetchServerError
+	default:
+		err.Kind = FetchUnexpectedStatus
+	}
+
+	err.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header, either in seconds or as HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+// NewFetcher creates a new fetcher using the best available proxy
+// Retries are read from the FETCH_RETRIES env var, 0 disables them
+func NewFetcher() *Fetcher {
+	return newFetcher(&http.Transport{
+		Proxy: GetBestProxy,
+	})
+}
+
+// NewDirectFetcher creates a new fetcher not using proxies
+// Retries are read from the FETCH_RETRIES env var, 0 disables them
+func NewDirectFetcher() *Fetcher {
+	return newFetcher(&http.Transport{})
+}
+
+func newFetcher(transport http.RoundTripper) *Fetcher {
+	return &Fetcher{
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+		MaxRetries:    GetEnvIntMin("FETCH_RETRIES", 4, 0),
+		BaseDelay:     2 * time.Second,
+		MaxDelay:      1 * time.Minute,
+		MaxRetryAfter: 5 * time.Minute,
+	}
+}
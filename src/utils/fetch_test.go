@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyResponse(t *testing.T) {
+	cases := []struct {
+		status    int
+		kind      FetchErrorKind
+		temporary bool
+	}{
+		{http.StatusFound, FetchUnexpectedStatus, false},
+		{http.StatusBadRequest, FetchUnexpectedStatus, false},
+		{http.StatusUnauthorized, FetchBlocked, false},
+		{http.StatusForbidden, FetchBlocked, false},
+		{http.StatusNotFound, FetchNotFound, false},
+		{http.StatusRequestTimeout, FetchRetryLater, true},
+		{http.StatusTooEarly, FetchRetryLater, true},
+		{http.StatusTooManyRequests, FetchRateLimited, true},
+		{http.StatusBadGateway, FetchServerError, true},
+	}
+
+	req := httptest.NewRequest("GET", "https://example.com", nil)
+
+	for _, c := range cases {
+		err := classifyResponse(req, &http.Response{StatusCode: c.status, Header: http.Header{}})
+
+		if err.Kind != c.kind || err.Temporary() != c.temporary {
+			t.Errorf("status %d: expected %s (temporary %t), got %s (temporary %t)", c.status, c.kind, c.temporary, err.Kind, err.Temporary())
+		}
+	}
+}
+
+func TestTokenBucketWaitCancel(t *testing.T) {
+	bucket := NewTokenBucket(0.1, 1)
+
+	if err := bucket.Wait(context.Background()); err != nil {
+		t.Fatalf("first token not taken: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := bucket.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("cancelled wait took %s", waited)
+	}
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FixtureMode is the enumerator type of fixture transport modes
+type FixtureMode string
+
+const (
+	// FixtureRecord mean responses are fetched and saved to the fixtures directory
+	FixtureRecord FixtureMode = "record"
+	// FixtureReplay mean responses are read from the fixtures directory only
+	FixtureReplay FixtureMode = "replay"
+)
+
+// Fixture is a recorded HTTP response
+type Fixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// FixtureTransport records responses to a directory or replays them offline
+type FixtureTransport struct {
+	Dir          string
+	Mode         FixtureMode
+	Next         http.RoundTripper
+	missing      []string
+	missingMutex sync.Mutex
+}
+
+// RoundTrip implements http.RoundTripper
+// Missing fixtures are replayed as 404 responses and listed by Missing
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, err := t.fixturePath(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Mode == FixtureRecord {
+		return t.record(req, path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		log.Printf("FIXTURE NOT FOUND FOR %s %s (%s)", req.Method, req.URL, path)
+
+		t.missingMutex.Lock()
+		t.missing = append(t.missing, req.Method+" "+req.URL.String())
+		t.missingMutex.Unlock()
+
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+
+	f := &Fixture{}
+	err = json.Unmarshal(data, f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(f.Status),
+		StatusCode: f.Status,
+		Header:     f.Header,
+		Body:       ioutil.NopCloser(strings.NewReader(f.Body)),
+		Request:    req,
+	}, nil
+}
+
+func (t *FixtureTransport) record(req *http.Request, path string) (*http.Response, error) {
+	resp, err := t.Next.RoundTrip(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Fixture{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: http.Header{"Content-Type": resp.Header["Content-Type"]},
+		Body:   string(body),
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+
+	if err == nil {
+		err = ioutil.WriteFile(path, data, 0644)
+	}
+
+	if err != nil {
+		log.Printf("FIXTURE NOT RECORDED FOR %s %s: %s", req.Method, req.URL, err.Error())
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// Missing returns the requests replayed without a fixture
+func (t *FixtureTransport) Missing() []string {
+	t.missingMutex.Lock()
+	defer t.missingMutex.Unlock()
+
+	return append([]string(nil), t.missing...)
+}
+
+// fixturePath returns the fixture file of a request, named after the host
+// and a hash of method, url and body
+func (t *FixtureTransport) fixturePath(req *http.Request) (string, error) {
+	hash := sha1.New()
+	hash.Write([]byte(req.Method + " " + req.URL.String() + "\n"))
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+
+		if err != nil {
+			return "", err
+		}
+
+		data, err := ioutil.ReadAll(body)
+
+		if err != nil {
+			return "", err
+		}
+
+		hash.Write(data)
+	}
+
+	name := hex.EncodeToString(hash.Sum(nil))[:16] + ".json"
+	return filepath.Join(t.Dir, normalizeHost(req.URL.Host), name), nil
+}
+
+// NewFixtureTransport creates a new fixture transport
+// Recorded responses are fetched through the proxied default transport
+func NewFixtureTransport(dir string, mode FixtureMode) *FixtureTransport {
+	return &FixtureTransport{
+		Dir:  dir,
+		Mode: mode,
+		Next: &http.Transport{
+			Proxy: GetBestProxy,
+		},
+	}
+}
+
+// UseTransport replaces the transport of the default and direct fetchers
+func UseTransport(transport http.RoundTripper) {
+	DefaultFetcher.Client.Transport = transport
+	DirectFetcher.Client.Transport = transport
+}
+
+// LoadFixtures sets up the default fetcher from the FIXTURES_MODE and
+// FIXTURES_DIR env vars; in replay mode no request leaves the process
+func LoadFixtures() {
+	mode := FixtureMode(os.Getenv("FIXTURES_MODE"))
+
+	if mode != FixtureRecord && mode != FixtureReplay {
+		return
+	}
+
+	UseFixtures(os.Getenv("FIXTURES_DIR"), mode)
+}
+
+// UseFixtures sets up the default fetcher to record or replay fixtures
+// Replayed requests are neither rate limited nor retried
+func UseFixtures(dir string, mode FixtureMode) *FixtureTransport {
+	if dir == "" {
+		dir = "fixtures"
+	}
+
+	transport := NewFixtureTransport(dir, mode)
+	UseTransport(transport)
+
+	if mode == FixtureReplay {
+		for _, f := range []*Fetcher{DefaultFetcher, DirectFetcher} {
+			f.MaxRetries = 0
+			f.Unlimited = true
+		}
+	}
+
+	log.Printf("FIXTURES %s MODE ON %s", strings.ToUpper(string(mode)), dir)
+
+	return transport
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// privateNetworks are the address ranges not reachable from the internet
+var privateNetworks = parseNetworks(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"fc00::/7",
+)
+
+// IsPublicIP checks if an ip address is reachable from the internet, so not
+// loopback, link-local, private, multicast or unspecified
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckPublicHost resolves a host and returns an error when any of its
+// addresses is not public
+func CheckPublicHost(host string) error {
+	ips, err := net.LookupIP(host)
+
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return fmt.Errorf("host %s resolves to the non public address %s", host, ip)
+		}
+	}
+
+	return nil
+}
+
+// PublicDialControl is a net.Dialer Control refusing connections to non
+// public addresses, checked after the name resolution of every dial
+func PublicDialControl(network string, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("address %s is not public", host)
+	}
+
+	return nil
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, n)
+	}
+
+	return networks
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a rate limiter which allows bursts up to its capacity
+type TokenBucket struct {
+	mutex    sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64
+	last     time.Time
+}
+
+// DefaultHostRate is the requests per second allowed to hosts without a specific limit
+var DefaultHostRate float64 = 2
+
+var hostRates = map[string]float64{
+	"myanimelist.net":    2,
+	"graphql.anilist.co": 1.5,
+}
+
+var hostBuckets = make(map[string]*TokenBucket)
+var hostBucketsMutex sync.Mutex
+
+// NewTokenBucket creates a new token bucket refilled at rate tokens per second
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		rate:     rate,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a token is available and takes it
+// The token is reserved at once, so waiting callers do not hold the bucket;
+// it is given back when the context is done first, returning its error
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	b.mutex.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	b.last = now
+
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	b.tokens--
+	missing := -b.tokens / b.rate
+	b.mutex.Unlock()
+
+	if missing <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(missing * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mutex.Lock()
+		b.tokens++
+		b.mutex.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+// LoadRateLimits reads per host limits from the RATE_LIMITS env var
+// The format is a comma separated list of host=requests per second, e.g.
+// RATE_LIMITS=myanimelist.net=2,graphql.anilist.co=1.5,*=3
+func LoadRateLimits() {
+	limits := os.Getenv("RATE_LIMITS")
+
+	hostBucketsMutex.Lock()
+	defer hostBucketsMutex.Unlock()
+
+	for _, limit := range strings.Split(limits, ",") {
+		parts := strings.Split(strings.TrimSpace(limit), "=")
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(parts[1], 64)
+
+		if err != nil || rate <= 0 {
+			continue
+		}
+
+		if parts[0] == "*" {
+			DefaultHostRate = rate
+		} else {
+			hostRates[normalizeHost(parts[0])] = rate
+		}
+	}
+
+	hostBuckets = make(map[string]*TokenBucket)
+}
+
+// WaitForHost blocks until a request to the given host is allowed
+// It returns the context error when the context is done first
+func WaitForHost(ctx context.Context, host string) error {
+	host = normalizeHost(host)
+
+	hostBucketsMutex.Lock()
+	bucket, ok := hostBuckets[host]
+
+	if !ok {
+		rate, ok := hostRates[host]
+
+		if !ok {
+			rate = DefaultHostRate
+		}
+
+		burst := int(rate)
+
+		if burst < 1 {
+			burst = 1
+		}
+
+		bucket = NewTokenBucket(rate, burst)
+		hostBuckets[host] = bucket
+	}
+
+	hostBucketsMutex.Unlock()
+
+	return bucket.Wait(ctx)
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+
+	if i := strings.LastIndex(host, ":"); i != -1 {
+		host = host[:i]
+	}
+
+	return strings.TrimPrefix(host, "www.")
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/gocolly/colly"
 )
@@ -21,6 +22,7 @@ type PageInfo struct {
 
 var proxies []*url.URL
 var proxiesUses []int
+var proxiesMutex sync.Mutex
 var pageSize int = 10
 
 func LoadProxies() {
@@ -50,6 +52,13 @@ func LoadProxies() {
 }
 
 func GetBestProxy(pr *http.Request) (*url.URL, error) {
+	proxiesMutex.Lock()
+	defer proxiesMutex.Unlock()
+
+	if len(proxies) == 0 {
+		return nil, nil
+	}
+
 	selected := &url.URL{}
 	best := math.MaxInt32
 	bestSelected := 0
@@ -95,3 +104,20 @@ func GetPageInfo(page int) *PageInfo {
 		Size:   pageSize,
 	}
 }
+
+// GetEnvInt returns a positive int env var value, or def if it is missing or invalid
+func GetEnvInt(name string, def int) int {
+	return GetEnvIntMin(name, def, 1)
+}
+
+// GetEnvIntMin returns an int env var value, or def if it is missing, invalid
+// or lower than min
+func GetEnvIntMin(name string, def int, min int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+
+	if err != nil || value < min {
+		return def
+	}
+
+	return value
+}